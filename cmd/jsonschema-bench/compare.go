@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// results maps the name of each benchmark to its averaged measurements.
+type results map[string]measurement
+
+// measurement is the average of the measurements of a benchmark. Fields which
+// were not reported are NaN.
+type measurement struct {
+	NsPerOp     float64
+	BytesPerOp  float64
+	AllocsPerOp float64
+}
+
+// parseResults reads the output of go test -bench. Lines other than benchmark
+// results are ignored. The GOMAXPROCS suffix is removed from names, so that
+// runs on different machines can be compared.
+func parseResults(r io.Reader) (results, error) {
+	type sums struct {
+		ns, bytes, allocs    float64
+		nsN, bytesN, allocsN int
+	}
+
+	totals := map[string]*sums{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			// not a result line, such as "BenchmarkFoo --- FAIL"
+			continue
+		}
+
+		name := trimProcs(strings.TrimPrefix(fields[0], "Benchmark"))
+		s, ok := totals[name]
+		if !ok {
+			s = &sums{}
+			totals[name] = s
+		}
+
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad measurement %q for %s", fields[i], fields[0])
+			}
+
+			switch fields[i+1] {
+			case "ns/op":
+				s.ns += value
+				s.nsN++
+			case "B/op":
+				s.bytes += value
+				s.bytesN++
+			case "allocs/op":
+				s.allocs += value
+				s.allocsN++
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	averages := results{}
+	for name, s := range totals {
+		averages[name] = measurement{
+			NsPerOp:     mean(s.ns, s.nsN),
+			BytesPerOp:  mean(s.bytes, s.bytesN),
+			AllocsPerOp: mean(s.allocs, s.allocsN),
+		}
+	}
+
+	return averages, nil
+}
+
+// trimProcs removes the "-N" suffix go test adds to benchmark names when
+// GOMAXPROCS is greater than one.
+func trimProcs(name string) string {
+	i := strings.LastIndexByte(name, '-')
+	if i < 0 {
+		return name
+	}
+
+	if _, err := strconv.Atoi(name[i+1:]); err != nil {
+		return name
+	}
+
+	return name[:i]
+}
+
+func mean(sum float64, n int) float64 {
+	if n == 0 {
+		return math.NaN()
+	}
+
+	return sum / float64(n)
+}
+
+// comparison is the change in a benchmark between two runs.
+type comparison struct {
+	Name      string
+	Old, New  measurement
+	InOld     bool
+	InNew     bool
+	Regressed bool
+}
+
+// compare pairs up the benchmarks of old and new, sorted by name. A benchmark
+// has regressed if its time or allocations per operation grew by more than
+// threshold percent.
+func compare(old, new results, threshold float64) []comparison {
+	names := []string{}
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range new {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	comparisons := make([]comparison, len(names))
+	for i, name := range names {
+		o, inOld := old[name]
+		n, inNew := new[name]
+
+		comparisons[i] = comparison{
+			Name:  name,
+			Old:   o,
+			New:   n,
+			InOld: inOld,
+			InNew: inNew,
+		}
+
+		if inOld && inNew {
+			comparisons[i].Regressed = grew(o.NsPerOp, n.NsPerOp, threshold) || grew(o.AllocsPerOp, n.AllocsPerOp, threshold)
+		}
+	}
+
+	return comparisons
+}
+
+// grew checks whether new is more than threshold percent greater than old.
+// Measurements missing from either run never count as growth.
+func grew(old, new, threshold float64) bool {
+	if math.IsNaN(old) || math.IsNaN(new) {
+		return false
+	}
+
+	if old == 0 {
+		return new > 0
+	}
+
+	return (new-old)/old*100 > threshold
+}
+
+func writeComparisons(w io.Writer, comparisons []comparison) error {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "name\told time/op\tnew time/op\tdelta\told allocs/op\tnew allocs/op\tdelta\t")
+
+	for _, c := range comparisons {
+		mark := ""
+		if c.Regressed {
+			mark = "REGRESSION"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			c.Name,
+			formatTime(c.Old.NsPerOp, c.InOld), formatTime(c.New.NsPerOp, c.InNew),
+			formatDelta(c.Old.NsPerOp, c.New.NsPerOp, c.InOld && c.InNew),
+			formatCount(c.Old.AllocsPerOp, c.InOld), formatCount(c.New.AllocsPerOp, c.InNew),
+			formatDelta(c.Old.AllocsPerOp, c.New.AllocsPerOp, c.InOld && c.InNew),
+			mark,
+		)
+	}
+
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	// the padding of the last column is left over on rows without a mark
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if line == "" {
+			continue
+		}
+
+		if _, err := io.WriteString(w, strings.TrimRight(line, " \n")+"\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func formatTime(ns float64, ok bool) string {
+	switch {
+	case !ok || math.IsNaN(ns):
+		return "-"
+	case ns >= 1e9:
+		return fmt.Sprintf("%.2fs", ns/1e9)
+	case ns >= 1e6:
+		return fmt.Sprintf("%.2fms", ns/1e6)
+	case ns >= 1e3:
+		return fmt.Sprintf("%.2fµs", ns/1e3)
+	default:
+		return fmt.Sprintf("%.2fns", ns)
+	}
+}
+
+func formatCount(n float64, ok bool) string {
+	if !ok || math.IsNaN(n) {
+		return "-"
+	}
+
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+func formatDelta(old, new float64, ok bool) string {
+	switch {
+	case !ok || math.IsNaN(old) || math.IsNaN(new):
+		return "-"
+	case old == new:
+		return "~"
+	case old == 0:
+		return "+inf"
+	default:
+		return fmt.Sprintf("%+.1f%%", (new-old)/old*100)
+	}
+}
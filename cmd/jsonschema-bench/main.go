@@ -0,0 +1,105 @@
+// Command jsonschema-bench compares two runs of this package's benchmarks.
+//
+// Usage:
+//
+//	go test -run '^$' -bench . -count 5 > old.txt
+//	# make changes
+//	go test -run '^$' -bench . -count 5 > new.txt
+//	jsonschema-bench [--threshold 10] old.txt new.txt
+//
+// Each input is the output of go test -bench, with -benchmem or
+// b.ReportAllocs for allocation figures. When a benchmark appears several
+// times, as with -count, its measurements are averaged.
+//
+// The exit status is 0 if no benchmark regressed, 1 if any benchmark's time or
+// allocations per operation grew by more than the threshold, and 2 if the
+// inputs could not be read.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	exitOK         = 0
+	exitRegression = 1
+	exitError      = 2
+)
+
+const usage = `usage: jsonschema-bench [--threshold percent] old.txt new.txt
+
+Compares the benchmark results in old.txt and new.txt, each the output of
+go test -bench. Benchmarks whose time or allocations per operation grew by
+more than the threshold are marked as regressions.
+
+flags:
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("jsonschema-bench", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	threshold := flags.Float64("threshold", 10, "percentage by which time or allocations per operation may grow before counting as a regression")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return exitError
+	}
+
+	oldResults, err := readResults(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "jsonschema-bench: %v\n", err)
+		return exitError
+	}
+
+	newResults, err := readResults(flags.Arg(1))
+	if err != nil {
+		fmt.Fprintf(stderr, "jsonschema-bench: %v\n", err)
+		return exitError
+	}
+
+	comparisons := compare(oldResults, newResults, *threshold)
+	if err := writeComparisons(stdout, comparisons); err != nil {
+		fmt.Fprintf(stderr, "jsonschema-bench: %v\n", err)
+		return exitError
+	}
+
+	for _, c := range comparisons {
+		if c.Regressed {
+			return exitRegression
+		}
+	}
+
+	return exitOK
+}
+
+func readResults(path string) (results, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	r, err := parseResults(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonschema-bench")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"old.txt": `goos: linux
+goarch: amd64
+pkg: github.com/json-schema-spec/json-schema-go
+BenchmarkValidate/valid-8   	  100000	     20000 ns/op	    1360 B/op	      25 allocs/op
+BenchmarkValidate/valid-8   	  100000	     18000 ns/op	    1360 B/op	      25 allocs/op
+BenchmarkPush-8             	20000000	        12 ns/op	       0 B/op	       0 allocs/op
+BenchmarkRemoved-8          	    1000	   1500000 ns/op
+PASS
+`,
+		"new.txt": `BenchmarkValidate/valid-4   	  100000	     15000 ns/op	    1248 B/op	      23 allocs/op
+BenchmarkPush-4             	20000000	        12 ns/op	      16 B/op	       1 allocs/op
+BenchmarkAdded-4            	    1000	   2000000 ns/op
+BenchmarkBroken-4           	--- FAIL: BenchmarkBroken
+`,
+		"same.txt": `BenchmarkValidate/valid 100000 19000 ns/op 1360 B/op 25 allocs/op
+`,
+		"slower.txt": `BenchmarkValidate/valid 100000 20000 ns/op 1360 B/op 25 allocs/op
+`,
+		"bad.txt": `BenchmarkValidate/valid 100000 fast ns/op
+`,
+	}
+
+	for name, contents := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644)
+		assert.NoError(t, err)
+	}
+
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+
+	testCases := []struct {
+		name   string
+		args   []string
+		status int
+		stdout string
+	}{
+		{
+			"no arguments",
+			[]string{},
+			exitError,
+			"",
+		},
+		{
+			"missing file",
+			[]string{path("old.txt"), path("missing.txt")},
+			exitError,
+			"",
+		},
+		{
+			"malformed file",
+			[]string{path("old.txt"), path("bad.txt")},
+			exitError,
+			"",
+		},
+		{
+			"regression",
+			[]string{path("old.txt"), path("new.txt")},
+			exitRegression,
+			"name            old time/op  new time/op  delta   old allocs/op  new allocs/op  delta\n" +
+				"Added           -            2.00ms       -       -              -              -\n" +
+				"Push            12.00ns      12.00ns      ~       0              1              +inf   REGRESSION\n" +
+				"Removed         1.50ms       -            -       -              -              -\n" +
+				"Validate/valid  19.00µs      15.00µs      -21.1%  25             23             -8.0%\n",
+		},
+		{
+			"within threshold",
+			[]string{path("same.txt"), path("slower.txt")},
+			exitOK,
+			"name            old time/op  new time/op  delta  old allocs/op  new allocs/op  delta\n" +
+				"Validate/valid  19.00µs      20.00µs      +5.3%  25             25             ~\n",
+		},
+		{
+			"beyond threshold",
+			[]string{"--threshold", "5", path("same.txt"), path("slower.txt")},
+			exitRegression,
+			"name            old time/op  new time/op  delta  old allocs/op  new allocs/op  delta\n" +
+				"Validate/valid  19.00µs      20.00µs      +5.3%  25             25             ~      REGRESSION\n",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			status := run(tt.args, &stdout, &stderr)
+
+			assert.Equal(t, tt.status, status, stderr.String())
+			assert.Equal(t, tt.stdout, stdout.String())
+		})
+	}
+}
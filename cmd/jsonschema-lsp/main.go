@@ -0,0 +1,146 @@
+// Command jsonschema-lsp is a Language Server Protocol server which checks JSON
+// documents against a JSON Schema as they are edited.
+//
+// Usage:
+//
+//	jsonschema-lsp --schema schema.json [--ref other.json ...]
+//
+// The server communicates with its client over standard input and output. It
+// publishes diagnostics for each open document as it changes, offers
+// completions of property names from "properties" and of values from "enum"
+// and "const", and shows the "title" and "description" of the part of the
+// schema applying to the value under the cursor.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+
+	jsonschema "github.com/json-schema-spec/json-schema-go"
+)
+
+const (
+	exitOK    = 0
+	exitError = 1
+)
+
+const usage = `usage: jsonschema-lsp --schema schema.json [--ref other.json ...]
+
+Serves the Language Server Protocol over standard input and output, checking
+documents against schema.json.
+
+flags:
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("jsonschema-lsp", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	var refs stringsFlag
+	schemaPath := flags.String("schema", "", "path to the schema to check documents against")
+	flags.Var(&refs, "ref", "path to a schema referred to by other schemas; may be repeated")
+
+	if err := flags.Parse(args); err != nil {
+		return exitError
+	}
+
+	if *schemaPath == "" {
+		fmt.Fprintln(stderr, "jsonschema-lsp: --schema is required")
+		return exitError
+	}
+
+	docs := []jsonschema.Document{}
+	for _, path := range append([]string{*schemaPath}, refs...) {
+		doc, err := readJSONFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "jsonschema-lsp: %v\n", err)
+			return exitError
+		}
+
+		docs = append(docs, doc)
+	}
+
+	uri, err := schemaURI(docs[0].Value)
+	if err != nil {
+		fmt.Fprintf(stderr, "jsonschema-lsp: %s: %v\n", *schemaPath, err)
+		return exitError
+	}
+
+	validator, err := jsonschema.NewValidatorFromDocuments(docs)
+	if err != nil {
+		fmt.Fprintf(stderr, "jsonschema-lsp: compiling schemas: %v\n", err)
+		return exitError
+	}
+
+	values := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		values[i] = doc.Value
+	}
+
+	s := newServer(validator, newSchemaIndex(&validator, values), uri, stdout)
+	status, err := s.Serve(stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "jsonschema-lsp: %v\n", err)
+	}
+
+	return status
+}
+
+// stringsFlag is a flag which may be given many times.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func readJSONFile(path string) (jsonschema.Document, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return jsonschema.Document{}, err
+	}
+
+	doc, err := jsonschema.ParseJSON(data)
+	if err != nil {
+		return jsonschema.Document{}, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return doc, nil
+}
+
+// schemaURI returns the URI which identifies a schema to a Validator.
+func schemaURI(schema interface{}) (url.URL, error) {
+	object, ok := schema.(map[string]interface{})
+	if !ok {
+		return url.URL{}, nil
+	}
+
+	id, ok := object["$id"].(string)
+	if !ok {
+		return url.URL{}, nil
+	}
+
+	uri, err := url.Parse(id)
+	if err != nil {
+		return url.URL{}, err
+	}
+
+	return *uri, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+
+	jsonpointer "github.com/json-schema-spec/json-pointer-go"
+	jsonschema "github.com/json-schema-spec/json-schema-go"
+)
+
+// schemaIndex finds which parts of a set of schemas apply to a location in an
+// instance, so that completions and hovers can be offered from them. It works
+// on the raw schemas, rather than a Validator, because it is interested in
+// keywords, like "title", that have no bearing on validation. References are
+// resolved by the Validator compiled from the same schemas, though, so that
+// they are resolved just as they are in validation.
+type schemaIndex struct {
+	validator *jsonschema.Validator
+
+	// schemas holds each raw schema, keyed by its fragment-less URI.
+	schemas map[url.URL]interface{}
+}
+
+// subschema is a raw schema, along with the URI of the schema it is part of and
+// the JSON Pointer tokens to it within that schema.
+type subschema struct {
+	base   url.URL
+	tokens []string
+	value  interface{}
+}
+
+// URI returns the URI identifying the subschema, as a Validator knows it.
+func (s subschema) URI() url.URL {
+	uri := s.base
+	uri.Fragment = jsonpointer.Ptr{Tokens: s.tokens}.String()
+	return uri
+}
+
+// child returns the part of the subschema at the given tokens.
+func (s subschema) child(value interface{}, tokens ...string) subschema {
+	childTokens := make([]string, 0, len(s.tokens)+len(tokens))
+	childTokens = append(childTokens, s.tokens...)
+	childTokens = append(childTokens, tokens...)
+	return subschema{base: s.base, tokens: childTokens, value: value}
+}
+
+func newSchemaIndex(validator *jsonschema.Validator, schemas []interface{}) schemaIndex {
+	index := schemaIndex{validator: validator, schemas: map[url.URL]interface{}{}}
+	for _, schema := range schemas {
+		uri := url.URL{}
+		if object, ok := schema.(map[string]interface{}); ok {
+			if id, ok := object["$id"].(string); ok {
+				if parsed, err := url.Parse(id); err == nil {
+					uri = *parsed
+					uri.Fragment = ""
+				}
+			}
+		}
+
+		if _, ok := index.schemas[uri]; !ok {
+			index.schemas[uri] = schema
+		}
+	}
+
+	return index
+}
+
+// At returns the subschemas of the schema with the given URI which apply to
+// the part of an instance at the given path. The instance itself is not
+// known, so every subschema which could apply is returned.
+func (i schemaIndex) At(uri url.URL, path []string) []subschema {
+	root, ok := i.lookup(uri)
+	if !ok {
+		return nil
+	}
+
+	schemas := i.Expand(root)
+	for _, token := range path {
+		children := []subschema{}
+		for _, schema := range schemas {
+			children = append(children, i.children(schema, token)...)
+		}
+
+		schemas = i.Expand(children...)
+	}
+
+	return schemas
+}
+
+// Expand returns schemas, along with any schemas they refer to or combine with
+// through "$ref", "allOf", "anyOf" and "oneOf". Each schema is returned only
+// once, however many ways it is reached, so that neither cycles of references
+// nor schemas combining the same schemas many times over make this expensive.
+func (i schemaIndex) Expand(schemas ...subschema) []subschema {
+	expanded := []subschema{}
+	seen := map[url.URL]bool{}
+
+	var expand func(schema subschema)
+	expand = func(schema subschema) {
+		uri := schema.URI()
+		if seen[uri] {
+			return
+		}
+
+		seen[uri] = true
+		expanded = append(expanded, schema)
+
+		object, ok := schema.value.(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		if ref, ok := i.validator.ResolveRef(uri); ok {
+			if target, ok := i.lookup(ref); ok {
+				expand(target)
+			}
+		}
+
+		for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+			if elems, ok := object[keyword].([]interface{}); ok {
+				for index, elem := range elems {
+					expand(schema.child(elem, keyword, strconv.Itoa(index)))
+				}
+			}
+		}
+	}
+
+	for _, schema := range schemas {
+		expand(schema)
+	}
+
+	return expanded
+}
+
+// lookup finds the schema with the given URI, whose fragment is a JSON Pointer
+// into a schema in the index.
+func (i schemaIndex) lookup(uri url.URL) (subschema, bool) {
+	fragment := uri.Fragment
+	uri.Fragment = ""
+
+	root, ok := i.schemas[uri]
+	if !ok {
+		return subschema{}, false
+	}
+
+	ptr, err := jsonpointer.New(fragment)
+	if err != nil {
+		return subschema{}, false
+	}
+
+	value, err := ptr.Eval(root)
+	if err != nil {
+		return subschema{}, false
+	}
+
+	return subschema{base: uri, tokens: ptr.Tokens, value: *value}, true
+}
+
+// children returns the subschemas of schema which apply to the member or
+// element of an instance with the given token.
+func (i schemaIndex) children(schema subschema, token string) []subschema {
+	object, ok := schema.value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	children := []subschema{}
+	child := func(value interface{}, tokens ...string) {
+		children = append(children, schema.child(value, tokens...))
+	}
+
+	// the instance may be an object
+	matched := false
+	if properties, ok := object["properties"].(map[string]interface{}); ok {
+		if value, ok := properties[token]; ok {
+			child(value, "properties", token)
+			matched = true
+		}
+	}
+
+	if patterns, ok := object["patternProperties"].(map[string]interface{}); ok {
+		for _, pattern := range sortedKeys(patterns) {
+			if re, err := regexp.Compile(pattern); err == nil && re.MatchString(token) {
+				child(patterns[pattern], "patternProperties", pattern)
+				matched = true
+			}
+		}
+	}
+
+	if value, ok := object["additionalProperties"]; ok && !matched {
+		child(value, "additionalProperties")
+	}
+
+	// or it may be an array
+	if index, err := strconv.Atoi(token); err == nil && index >= 0 {
+		switch items := object["items"].(type) {
+		case []interface{}:
+			if index < len(items) {
+				child(items[index], "items", strconv.Itoa(index))
+			} else if value, ok := object["additionalItems"]; ok {
+				child(value, "additionalItems")
+			}
+		case nil:
+		default:
+			child(items, "items")
+		}
+	}
+
+	return children
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
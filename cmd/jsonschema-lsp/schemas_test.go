@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"testing"
+
+	jsonschema "github.com/json-schema-spec/json-schema-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSchemaIndex(t *testing.T, schemas ...interface{}) schemaIndex {
+	validator, err := jsonschema.NewValidator(schemas)
+	assert.NoError(t, err)
+
+	return newSchemaIndex(&validator, schemas)
+}
+
+func TestSchemaIndexAt(t *testing.T) {
+	var schema interface{}
+	err := json.Unmarshal([]byte(`{
+		"properties": {
+			"a": { "$ref": "#/definitions/a" },
+			"b": { "$ref": "http://example.com/b#/definitions/b" }
+		},
+		"definitions": {
+			"a": { "title": "A", "items": { "title": "A item" } }
+		}
+	}`), &schema)
+	assert.NoError(t, err)
+
+	var other interface{}
+	err = json.Unmarshal([]byte(`{
+		"$id": "http://example.com/b",
+		"definitions": {
+			"b": { "allOf": [{ "$ref": "#/definitions/c" }] },
+			"c": { "title": "C" }
+		}
+	}`), &other)
+	assert.NoError(t, err)
+
+	index := newTestSchemaIndex(t, schema, other)
+
+	titles := func(path ...string) []string {
+		titles := []string{}
+		for _, s := range index.At(url.URL{}, path) {
+			if object, ok := s.value.(map[string]interface{}); ok {
+				if title, ok := object["title"].(string); ok {
+					titles = append(titles, title)
+				}
+			}
+		}
+
+		return titles
+	}
+
+	assert.Equal(t, []string{"A"}, titles("a"))
+	assert.Equal(t, []string{"A item"}, titles("a", "0"))
+	assert.Equal(t, []string{"C"}, titles("b"))
+	assert.Equal(t, []string{}, titles("c"))
+}
+
+func TestSchemaIndexExpandOnce(t *testing.T) {
+	// each definition combines the next twice over, so following every path
+	// through them would take time exponential in their number
+	definitions := map[string]interface{}{}
+	for i := 0; i < 40; i++ {
+		next := map[string]interface{}{"$ref": fmt.Sprintf("#/definitions/d%d", i+1)}
+		definitions[fmt.Sprintf("d%d", i)] = map[string]interface{}{
+			"allOf": []interface{}{next, next},
+			"anyOf": []interface{}{next, next},
+		}
+	}
+
+	definitions["d40"] = map[string]interface{}{"title": "last"}
+
+	index := newTestSchemaIndex(t, map[string]interface{}{
+		"$ref":        "#/definitions/d0",
+		"definitions": definitions,
+	})
+
+	schemas := index.At(url.URL{}, nil)
+
+	// the root, then for each definition, the definition and its four
+	// branches
+	assert.Equal(t, 1+40*5+1, len(schemas))
+
+	title, _ := (&server{}).describe(schemas)
+	assert.Equal(t, "last", title)
+
+	// recursion is only followed as far as it takes to reach each schema once
+	index = newTestSchemaIndex(t, map[string]interface{}{
+		"anyOf": []interface{}{
+			map[string]interface{}{},
+			map[string]interface{}{"$ref": "#"},
+		},
+	})
+
+	assert.Equal(t, 3, len(index.At(url.URL{}, nil)))
+}
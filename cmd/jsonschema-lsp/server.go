@@ -0,0 +1,431 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	jsonschema "github.com/json-schema-spec/json-schema-go"
+)
+
+// server is a language server which validates JSON documents against a schema.
+type server struct {
+	validator jsonschema.Validator
+	index     schemaIndex
+
+	// uri identifies the schema documents are validated against.
+	uri url.URL
+
+	out       io.Writer
+	documents map[string]*document
+
+	// shutdown indicates that the client has asked the server to shut down,
+	// and will next ask it to exit.
+	shutdown bool
+}
+
+func newServer(validator jsonschema.Validator, index schemaIndex, uri url.URL, out io.Writer) *server {
+	return &server{
+		validator: validator,
+		index:     index,
+		uri:       uri,
+		out:       out,
+		documents: map[string]*document{},
+	}
+}
+
+// Serve handles messages from in until the client asks the server to exit, and
+// returns the status the server should exit with.
+func (s *server) Serve(in io.Reader) (int, error) {
+	r := bufio.NewReader(in)
+
+	for {
+		body, err := readMessage(r)
+		if err == io.EOF {
+			return exitError, nil
+		}
+
+		if err != nil {
+			return exitError, err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := s.replyError(nil, codeParseError, err.Error()); err != nil {
+				return exitError, err
+			}
+
+			continue
+		}
+
+		if req.Method == "exit" {
+			if s.shutdown {
+				return exitOK, nil
+			}
+
+			return exitError, nil
+		}
+
+		if err := s.handle(req); err != nil {
+			return exitError, err
+		}
+	}
+}
+
+// handle dispatches a single message. Only errors writing to the client are
+// returned; errors in handling the message are reported to the client.
+func (s *server) handle(req request) error {
+	var result interface{}
+	var err error
+
+	switch req.Method {
+	case "initialize":
+		result = s.initialize()
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			err = s.didOpen(params)
+		}
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			err = s.didChange(params)
+		}
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			err = s.didClose(params)
+		}
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			result = s.completion(params)
+		}
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			result = s.hover(params)
+		}
+	default:
+		if req.ID == nil {
+			// notifications the server does not support, such as
+			// "initialized", are ignored
+			return nil
+		}
+
+		return s.replyError(req.ID, codeMethodNotFound, fmt.Sprintf("method not found: %s", req.Method))
+	}
+
+	if req.ID == nil {
+		// notifications cannot be replied to, even with errors
+		return nil
+	}
+
+	if _, ok := err.(*json.UnmarshalTypeError); ok {
+		return s.replyError(req.ID, codeInvalidParams, err.Error())
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return s.reply(req.ID, result)
+}
+
+func (s *server) reply(id *json.RawMessage, result interface{}) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	raw := json.RawMessage(data)
+	return writeMessage(s.out, response{JSONRPC: "2.0", ID: id, Result: &raw})
+}
+
+func (s *server) replyError(id *json.RawMessage, code int, message string) error {
+	return writeMessage(s.out, response{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   &responseError{Code: code, Message: message},
+	})
+}
+
+func (s *server) notify(method string, params interface{}) error {
+	return writeMessage(s.out, notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *server) initialize() initializeResult {
+	return initializeResult{
+		Capabilities: serverCapabilities{
+			TextDocumentSync: textDocumentSyncOptions{
+				OpenClose: true,
+				Change:    syncIncremental,
+			},
+			CompletionProvider: completionOptions{
+				TriggerCharacters: []string{`"`, ":"},
+			},
+			HoverProvider: true,
+		},
+		ServerInfo: serverInfo{Name: "jsonschema-lsp"},
+	}
+}
+
+func (s *server) didOpen(params didOpenParams) error {
+	doc := &document{version: params.TextDocument.Version, text: params.TextDocument.Text}
+	s.documents[params.TextDocument.URI] = doc
+	return s.publishDiagnostics(params.TextDocument.URI, doc)
+}
+
+func (s *server) didChange(params didChangeParams) error {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+
+	for _, change := range params.ContentChanges {
+		doc.apply(change)
+	}
+
+	doc.version = params.TextDocument.Version
+	return s.publishDiagnostics(params.TextDocument.URI, doc)
+}
+
+func (s *server) didClose(params didCloseParams) error {
+	delete(s.documents, params.TextDocument.URI)
+
+	// diagnostics for closed documents are cleared
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         params.TextDocument.URI,
+		Diagnostics: []diagnostic{},
+	})
+}
+
+// publishDiagnostics validates a document and sends the client the problems
+// found with it.
+func (s *server) publishDiagnostics(uri string, doc *document) error {
+	return s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         uri,
+		Version:     doc.version,
+		Diagnostics: s.diagnose(doc.text),
+	})
+}
+
+func (s *server) diagnose(text string) []diagnostic {
+	diagnostics := []diagnostic{}
+
+	instance, err := jsonschema.ParseJSON([]byte(text))
+	if err != nil {
+		rng := textRange{}
+		if syntaxErr, ok := err.(jsonschema.ErrSyntax); ok {
+			pos := positionAt(text, syntaxErr.Position.Offset)
+			rng = textRange{Start: pos, End: pos}
+		}
+
+		return append(diagnostics, newDiagnostic(rng, err.Error()))
+	}
+
+	result, err := s.validator.ValidateURIDocument(s.uri, instance)
+	if err != nil {
+		return append(diagnostics, newDiagnostic(textRange{}, err.Error()))
+	}
+
+	offsets := make([]int, 0, 2*len(result.Errors))
+	for _, e := range result.Errors {
+		offsets = append(offsets, e.InstanceStart.Offset, e.InstanceEnd.Offset)
+	}
+
+	positions := positionsAt(text, offsets)
+	for i, e := range result.Errors {
+		rng := textRange{Start: positions[2*i], End: positions[2*i+1]}
+		diagnostics = append(diagnostics, newDiagnostic(rng, describeError(e)))
+	}
+
+	if result.Overflowed {
+		more := result.ErrorCount - len(result.Errors)
+		diagnostics = append(diagnostics, newDiagnostic(textRange{}, fmt.Sprintf("and %d more errors", more)))
+	}
+
+	return diagnostics
+}
+
+func newDiagnostic(rng textRange, message string) diagnostic {
+	return diagnostic{
+		Range:    rng,
+		Severity: severityError,
+		Source:   "jsonschema",
+		Message:  message,
+	}
+}
+
+// describeError produces a message for a validation error, naming the keyword
+// which rejected the value.
+func describeError(e jsonschema.ValidationError) string {
+	keyword := ""
+	for i := len(e.SchemaPath.Tokens) - 1; i >= 0; i-- {
+		if _, err := strconv.Atoi(e.SchemaPath.Tokens[i]); err != nil {
+			keyword = e.SchemaPath.Tokens[i]
+			break
+		}
+	}
+
+	location := e.URI
+	location.Fragment = e.SchemaPath.String()
+
+	if keyword == "" {
+		return fmt.Sprintf("rejected by %s", location.String())
+	}
+
+	return fmt.Sprintf("rejected by %q (%s)", keyword, location.String())
+}
+
+func (s *server) completion(params textDocumentPositionParams) []completionItem {
+	items := []completionItem{}
+
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return items
+	}
+
+	offset := offsetAt(doc.text, params.Position)
+	ctx := findCursorContext(doc.text, offset)
+	rng := textRange{
+		Start: positionAt(doc.text, ctx.tokenStart),
+		End:   positionAt(doc.text, ctx.tokenEnd),
+	}
+
+	seen := map[string]bool{}
+	for _, schema := range s.index.At(s.uri, ctx.path) {
+		object, ok := schema.value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if ctx.inKey {
+			properties, _ := object["properties"].(map[string]interface{})
+			for _, name := range sortedKeys(properties) {
+				if seen[name] || ctx.keys[name] {
+					continue
+				}
+
+				seen[name] = true
+				title, description := s.describe(s.index.Expand(schema.child(properties[name], "properties", name)))
+
+				items = append(items, completionItem{
+					Label:         name,
+					Kind:          completionKindProperty,
+					Detail:        title,
+					Documentation: description,
+					TextEdit:      &textEdit{Range: rng, NewText: encodeJSON(name)},
+				})
+			}
+
+			continue
+		}
+
+		values := []interface{}{}
+		if enum, ok := object["enum"].([]interface{}); ok {
+			values = append(values, enum...)
+		}
+
+		if value, ok := object["const"]; ok {
+			values = append(values, value)
+		}
+
+		for _, value := range values {
+			text := encodeJSON(value)
+			if seen[text] {
+				continue
+			}
+
+			seen[text] = true
+			items = append(items, completionItem{
+				Label:    text,
+				Kind:     completionKindValue,
+				TextEdit: &textEdit{Range: rng, NewText: text},
+			})
+		}
+	}
+
+	return items
+}
+
+func (s *server) hover(params textDocumentPositionParams) *hover {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+
+	offset := offsetAt(doc.text, params.Position)
+	ctx := findCursorContext(doc.text, offset)
+
+	path := ctx.path
+	if ctx.inKey {
+		if ctx.tokenStart == ctx.tokenEnd {
+			// the cursor is between members, not on one
+			return nil
+		}
+
+		path = append(path, ctx.key)
+	}
+
+	title, description := s.describe(s.index.At(s.uri, path))
+	if title == "" && description == "" {
+		return nil
+	}
+
+	parts := []string{}
+	if title != "" {
+		parts = append(parts, "**"+title+"**")
+	}
+
+	if description != "" {
+		parts = append(parts, description)
+	}
+
+	h := hover{Contents: markupContent{Kind: "markdown", Value: strings.Join(parts, "\n\n")}}
+	if ctx.tokenStart < ctx.tokenEnd {
+		h.Range = &textRange{
+			Start: positionAt(doc.text, ctx.tokenStart),
+			End:   positionAt(doc.text, ctx.tokenEnd),
+		}
+	}
+
+	return &h
+}
+
+// describe returns the first "title" and "description" found among schemas.
+func (s *server) describe(schemas []subschema) (title, description string) {
+	for _, schema := range schemas {
+		object, ok := schema.value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if value, ok := object["title"].(string); ok && title == "" {
+			title = value
+		}
+
+		if value, ok := object["description"].(string); ok && description == "" {
+			description = value
+		}
+	}
+
+	return title, description
+}
+
+// encodeJSON encodes a value parsed from JSON back into JSON.
+func encodeJSON(value interface{}) string {
+	var buf strings.Builder
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	// values parsed from JSON can always be encoded again
+	_ = enc.Encode(value)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
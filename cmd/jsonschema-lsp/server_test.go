@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"testing"
+
+	jsonschema "github.com/json-schema-spec/json-schema-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// testClient is a minimal Language Server Protocol client, which talks to a
+// server running in another goroutine.
+type testClient struct {
+	t      *testing.T
+	in     *io.PipeWriter
+	out    *bufio.Reader
+	nextID int
+	status chan int
+}
+
+func newTestClient(t *testing.T, schemas ...string) *testClient {
+	docs := []jsonschema.Document{}
+	values := []interface{}{}
+	for _, schema := range schemas {
+		doc, err := jsonschema.ParseJSON([]byte(schema))
+		assert.NoError(t, err)
+
+		docs = append(docs, doc)
+		values = append(values, doc.Value)
+	}
+
+	validator, err := jsonschema.NewValidatorFromDocuments(docs)
+	assert.NoError(t, err)
+
+	clientIn, serverOut := io.Pipe()
+	serverIn, clientOut := io.Pipe()
+
+	c := &testClient{t: t, in: clientOut, out: bufio.NewReader(clientIn), status: make(chan int, 1)}
+	s := newServer(validator, newSchemaIndex(&validator, values), url.URL{}, serverOut)
+
+	go func() {
+		status, err := s.Serve(serverIn)
+		assert.NoError(t, err)
+
+		serverOut.Close()
+		c.status <- status
+	}()
+
+	c.Request("initialize", map[string]interface{}{}, nil)
+	c.Notify("initialized", map[string]interface{}{})
+	return c
+}
+
+// Request sends a request, and decodes the result of the reply into result.
+func (c *testClient) Request(method string, params interface{}, result interface{}) {
+	c.nextID++
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+
+	err := writeMessage(c.in, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      &id,
+		"method":  method,
+		"params":  params,
+	})
+	assert.NoError(c.t, err)
+
+	var resp struct {
+		ID     json.RawMessage `json:"id"`
+		Result json.RawMessage `json:"result"`
+		Error  *responseError  `json:"error"`
+	}
+
+	c.read(&resp)
+	assert.Equal(c.t, string(id), string(resp.ID))
+	assert.Nil(c.t, resp.Error)
+
+	if result != nil {
+		assert.NoError(c.t, json.Unmarshal(resp.Result, result))
+	}
+}
+
+// Notify sends a notification.
+func (c *testClient) Notify(method string, params interface{}) {
+	err := writeMessage(c.in, map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+	})
+	assert.NoError(c.t, err)
+}
+
+// Diagnostics reads the next message, which must be published diagnostics.
+func (c *testClient) Diagnostics() publishDiagnosticsParams {
+	var msg struct {
+		Method string                   `json:"method"`
+		Params publishDiagnosticsParams `json:"params"`
+	}
+
+	c.read(&msg)
+	assert.Equal(c.t, "textDocument/publishDiagnostics", msg.Method)
+	return msg.Params
+}
+
+// Exit shuts down the server, returning its exit status.
+func (c *testClient) Exit() int {
+	c.Request("shutdown", nil, nil)
+	c.Notify("exit", nil)
+	return <-c.status
+}
+
+func (c *testClient) read(v interface{}) {
+	body, err := readMessage(c.out)
+	assert.NoError(c.t, err)
+	assert.NoError(c.t, json.Unmarshal(body, v))
+}
+
+const testSchema = `{
+  "title": "Config",
+  "properties": {
+    "name": { "type": "string", "title": "Name", "description": "What to call it." },
+    "mode": { "enum": ["fast", "slow", 3] },
+    "server": { "$ref": "#/definitions/server" }
+  },
+  "definitions": {
+    "server": {
+      "description": "Where to connect.",
+      "properties": {
+        "host": { "type": "string" },
+        "tls": { "const": true }
+      }
+    }
+  }
+}`
+
+func open(c *testClient, text string) publishDiagnosticsParams {
+	c.Notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{
+			"uri":        "file:///config.json",
+			"languageId": "json",
+			"version":    1,
+			"text":       text,
+		},
+	})
+
+	return c.Diagnostics()
+}
+
+func TestServerDiagnostics(t *testing.T) {
+	c := newTestClient(t, testSchema)
+
+	diagnostics := open(c, "{\n  \"name\": 3\n}")
+	assert.Equal(t, 1, diagnostics.Version)
+	assert.Equal(t, []diagnostic{
+		diagnostic{
+			Range:    textRange{Start: position{Line: 1, Character: 10}, End: position{Line: 1, Character: 11}},
+			Severity: severityError,
+			Source:   "jsonschema",
+			Message:  `rejected by "type" (#/properties/name/type)`,
+		},
+	}, diagnostics.Diagnostics)
+
+	// replace the 3 with a string, incrementally
+	c.Notify("textDocument/didChange", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": "file:///config.json", "version": 2},
+		"contentChanges": []map[string]interface{}{
+			{
+				"range": textRange{Start: position{Line: 1, Character: 10}, End: position{Line: 1, Character: 11}},
+				"text":  `"x"`,
+			},
+		},
+	})
+
+	diagnostics = c.Diagnostics()
+	assert.Equal(t, 2, diagnostics.Version)
+	assert.Equal(t, []diagnostic{}, diagnostics.Diagnostics)
+
+	// break the syntax, replacing the whole document
+	c.Notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": "file:///config.json", "version": 3},
+		"contentChanges": []map[string]interface{}{{"text": "{\n  \"name\": }"}},
+	})
+
+	diagnostics = c.Diagnostics()
+	assert.Equal(t, 1, len(diagnostics.Diagnostics))
+	assert.Equal(t, position{Line: 1, Character: 10}, diagnostics.Diagnostics[0].Range.Start)
+
+	c.Notify("textDocument/didClose", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": "file:///config.json"},
+	})
+
+	diagnostics = c.Diagnostics()
+	assert.Equal(t, []diagnostic{}, diagnostics.Diagnostics)
+
+	assert.Equal(t, exitOK, c.Exit())
+}
+
+func TestServerCompletion(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		position position
+		labels   []string
+		edit     textRange
+	}{
+		{
+			"property names",
+			`{ "name": "a",  }`,
+			position{Character: 15},
+			[]string{"mode", "server"},
+			textRange{Start: position{Character: 15}, End: position{Character: 15}},
+		},
+		{
+			"within a key",
+			`{ "se }`,
+			position{Character: 5},
+			[]string{"mode", "name", "server"},
+			textRange{Start: position{Character: 2}, End: position{Character: 5}},
+		},
+		{
+			"through a ref",
+			`{ "server": { } }`,
+			position{Character: 14},
+			[]string{"host", "tls"},
+			textRange{Start: position{Character: 14}, End: position{Character: 14}},
+		},
+		{
+			"enum values",
+			`{ "mode": }`,
+			position{Character: 10},
+			[]string{`"fast"`, `"slow"`, `3`},
+			textRange{Start: position{Character: 10}, End: position{Character: 10}},
+		},
+		{
+			"const values",
+			`{ "server": { "tls": f`,
+			position{Character: 22},
+			[]string{`true`},
+			textRange{Start: position{Character: 21}, End: position{Character: 22}},
+		},
+		{
+			"nothing known",
+			`{ "other": }`,
+			position{Character: 11},
+			[]string{},
+			textRange{},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, testSchema)
+			open(c, tt.text)
+
+			var items []completionItem
+			c.Request("textDocument/completion", textDocumentPositionParams{
+				TextDocument: textDocumentIdentifier{URI: "file:///config.json"},
+				Position:     tt.position,
+			}, &items)
+
+			labels := []string{}
+			for _, item := range items {
+				labels = append(labels, item.Label)
+				assert.Equal(t, tt.edit, item.TextEdit.Range)
+			}
+
+			assert.Equal(t, tt.labels, labels)
+			assert.Equal(t, exitOK, c.Exit())
+		})
+	}
+}
+
+func TestServerHover(t *testing.T) {
+	testCases := []struct {
+		name     string
+		text     string
+		position position
+		hover    *hover
+	}{
+		{
+			"key",
+			`{ "name": "x" }`,
+			position{Character: 4},
+			&hover{
+				Contents: markupContent{Kind: "markdown", Value: "**Name**\n\nWhat to call it."},
+				Range:    &textRange{Start: position{Character: 2}, End: position{Character: 8}},
+			},
+		},
+		{
+			"value through a ref",
+			`{ "server": {} }`,
+			position{Character: 12},
+			&hover{
+				Contents: markupContent{Kind: "markdown", Value: "Where to connect."},
+			},
+		},
+		{
+			"root",
+			` {}`,
+			position{Character: 0},
+			&hover{
+				Contents: markupContent{Kind: "markdown", Value: "**Config**"},
+			},
+		},
+		{
+			"between members",
+			`{ "name": "x" ,  }`,
+			position{Character: 16},
+			nil,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, testSchema)
+			open(c, tt.text)
+
+			var h *hover
+			c.Request("textDocument/hover", textDocumentPositionParams{
+				TextDocument: textDocumentIdentifier{URI: "file:///config.json"},
+				Position:     tt.position,
+			}, &h)
+
+			assert.Equal(t, tt.hover, h)
+			assert.Equal(t, exitOK, c.Exit())
+		})
+	}
+}
+
+func TestServerUnknownMethod(t *testing.T) {
+	c := newTestClient(t, testSchema)
+
+	err := writeMessage(c.in, map[string]interface{}{"jsonrpc": "2.0", "id": 99, "method": "workspace/symbol"})
+	assert.NoError(t, err)
+
+	var resp response
+	c.read(&resp)
+	assert.Equal(t, codeMethodNotFound, resp.Error.Code)
+
+	// exiting without shutting down first is an error
+	c.Notify("exit", nil)
+	assert.Equal(t, exitError, <-c.status)
+}
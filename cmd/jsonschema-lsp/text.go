@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// document is the state of a document the client has opened.
+type document struct {
+	version int
+	text    string
+}
+
+// apply performs an edit on the document's text.
+func (d *document) apply(change contentChange) {
+	if change.Range == nil {
+		d.text = change.Text
+		return
+	}
+
+	start := offsetAt(d.text, change.Range.Start)
+	end := offsetAt(d.text, change.Range.End)
+	if end < start {
+		end = start
+	}
+
+	d.text = d.text[:start] + change.Text + d.text[end:]
+}
+
+// offsetAt converts a protocol position into a byte offset in text. Protocol
+// positions count characters in UTF-16 code units. Positions past the end of a
+// line or of the text are clamped to the end of it.
+func offsetAt(text string, pos position) int {
+	offset := 0
+	for line := 0; line < pos.Line; line++ {
+		next := indexByteFrom(text, offset, '\n')
+		if next < 0 {
+			return len(text)
+		}
+
+		offset = next + 1
+	}
+
+	for units := 0; units < pos.Character && offset < len(text); {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		if r == '\n' {
+			break
+		}
+
+		units += utf16Len(r)
+		offset += size
+	}
+
+	return offset
+}
+
+// positionAt converts a byte offset in text into a protocol position.
+func positionAt(text string, offset int) position {
+	if offset > len(text) {
+		offset = len(text)
+	}
+
+	pos := position{}
+	for i := 0; i < offset; {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if r == '\n' {
+			pos.Line++
+			pos.Character = 0
+		} else {
+			pos.Character += utf16Len(r)
+		}
+
+		i += size
+	}
+
+	return pos
+}
+
+// positionsAt converts many byte offsets in text into protocol positions. The
+// text is scanned once for all of them, rather than once for each, as
+// positionAt would.
+func positionsAt(text string, offsets []int) []position {
+	order := make([]int, len(offsets))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.Slice(order, func(i, j int) bool {
+		return offsets[order[i]] < offsets[order[j]]
+	})
+
+	positions := make([]position, len(offsets))
+	pos := position{}
+	i := 0
+	for _, index := range order {
+		offset := offsets[index]
+		if offset > len(text) {
+			offset = len(text)
+		}
+
+		for i < offset {
+			r, size := utf8.DecodeRuneInString(text[i:])
+			if r == '\n' {
+				pos.Line++
+				pos.Character = 0
+			} else {
+				pos.Character += utf16Len(r)
+			}
+
+			i += size
+		}
+
+		positions[index] = pos
+	}
+
+	return positions
+}
+
+func indexByteFrom(s string, from int, c byte) int {
+	for i := from; i < len(s); i++ {
+		if s[i] == c {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// cursorContext describes where in the structure of a JSON document a cursor
+// is. It is found by scanning only the text before the cursor, so that it can
+// be found even while the document is incomplete or malformed, as it usually
+// is while being edited.
+type cursorContext struct {
+	// path holds the tokens of a JSON Pointer to the value at the cursor. If
+	// the cursor is where an object's key goes, it instead points to the
+	// object.
+	path []string
+
+	// inKey indicates that the cursor is where an object's key goes.
+	inKey bool
+
+	// key is the key the cursor is within, if inKey is true and the cursor is
+	// within a string.
+	key string
+
+	// keys holds the keys already present before the cursor in the object, if
+	// inKey is true.
+	keys map[string]bool
+
+	// tokenStart and tokenEnd are the offsets of the string, number or literal
+	// the cursor is within. If the cursor is not within one, both are the
+	// offset of the cursor.
+	tokenStart int
+	tokenEnd   int
+}
+
+// scanFrame is an array or object being scanned by findCursorContext.
+type scanFrame struct {
+	object    bool
+	expectKey bool
+	key       string
+	index     int
+	keys      map[string]bool
+}
+
+func (f scanFrame) token() string {
+	if f.object {
+		return f.key
+	}
+
+	return strconv.Itoa(f.index)
+}
+
+// findCursorContext finds the context of a cursor at the given offset in text.
+func findCursorContext(text string, offset int) cursorContext {
+	if offset > len(text) {
+		offset = len(text)
+	}
+
+	stack := []scanFrame{}
+	ctx := cursorContext{tokenStart: offset, tokenEnd: offset}
+
+	i := 0
+	for i < offset {
+		switch c := text[i]; c {
+		case ' ', '\t', '\n', '\r':
+			i++
+		case '{':
+			stack = append(stack, scanFrame{object: true, expectKey: true, keys: map[string]bool{}})
+			i++
+		case '[':
+			stack = append(stack, scanFrame{})
+			i++
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+
+			i++
+		case ':':
+			if len(stack) > 0 {
+				stack[len(stack)-1].expectKey = false
+			}
+
+			i++
+		case ',':
+			if len(stack) > 0 {
+				top := &stack[len(stack)-1]
+				if top.object {
+					top.expectKey = true
+					top.key = ""
+				} else {
+					top.index++
+				}
+			}
+
+			i++
+		case '"':
+			end := scanString(text, i)
+			s := decodeString(text[i:end])
+
+			if offset < end || (offset == end && !terminated(text[i:end])) {
+				// the cursor is within the string. If the string is not
+				// terminated, then what follows the cursor is probably not part
+				// of it
+				ctx.tokenStart = i
+				ctx.tokenEnd = end
+				if !terminated(text[i:end]) {
+					ctx.tokenEnd = offset
+				}
+
+				if len(stack) > 0 && stack[len(stack)-1].object && stack[len(stack)-1].expectKey {
+					ctx.key = s
+				}
+
+				return ctx.finish(stack)
+			}
+
+			if len(stack) > 0 && stack[len(stack)-1].object && stack[len(stack)-1].expectKey {
+				stack[len(stack)-1].key = s
+				stack[len(stack)-1].keys[s] = true
+			}
+
+			i = end
+		default:
+			end := i + 1
+			for end < len(text) && !isDelimiter(text[end]) {
+				end++
+			}
+
+			if offset < end || offset == end {
+				// the cursor is within, or just after, a number or literal
+				ctx.tokenStart = i
+				ctx.tokenEnd = end
+				return ctx.finish(stack)
+			}
+
+			i = end
+		}
+	}
+
+	return ctx.finish(stack)
+}
+
+// finish fills in the path of the context from the frames enclosing the
+// cursor.
+func (ctx cursorContext) finish(stack []scanFrame) cursorContext {
+	ctx.path = []string{}
+	for i, frame := range stack {
+		if i == len(stack)-1 && frame.object && frame.expectKey {
+			ctx.inKey = true
+			ctx.keys = frame.keys
+			break
+		}
+
+		ctx.path = append(ctx.path, frame.token())
+	}
+
+	return ctx
+}
+
+// scanString returns the offset just past the end of the string starting at
+// start, or the end of text if the string is not terminated.
+func scanString(text string, start int) int {
+	for i := start + 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		case '\n':
+			// strings cannot contain newlines, so the string was never closed
+			return i
+		}
+	}
+
+	return len(text)
+}
+
+func terminated(s string) bool {
+	return len(s) >= 2 && s[len(s)-1] == '"'
+}
+
+// decodeString decodes a JSON string, tolerating it being unterminated or
+// malformed.
+func decodeString(s string) string {
+	var decoded string
+	if err := json.Unmarshal([]byte(s), &decoded); err == nil {
+		return decoded
+	}
+
+	if terminated(s) {
+		return s[1 : len(s)-1]
+	}
+
+	return s[1:]
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', ',', ':', '{', '}', '[', ']', '"':
+		return true
+	default:
+		return false
+	}
+}
+
+// utf16Len returns the number of UTF-16 code units that encode r.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+
+	return 1
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOffsetAt(t *testing.T) {
+	text := "ab\n€😀x\n"
+
+	testCases := []struct {
+		position position
+		offset   int
+	}{
+		{position{Line: 0, Character: 0}, 0},
+		{position{Line: 0, Character: 2}, 2},
+		{position{Line: 0, Character: 9}, 2},
+		{position{Line: 1, Character: 1}, 6},
+		{position{Line: 1, Character: 3}, 10},
+		{position{Line: 1, Character: 4}, 11},
+		{position{Line: 2, Character: 0}, 12},
+		{position{Line: 5, Character: 0}, 12},
+	}
+
+	for _, tt := range testCases {
+		assert.Equal(t, tt.offset, offsetAt(text, tt.position), "%v", tt.position)
+	}
+
+	assert.Equal(t, position{Line: 1, Character: 4}, positionAt(text, 11))
+	assert.Equal(t, position{Line: 2, Character: 0}, positionAt(text, 12))
+}
+
+func TestPositionsAt(t *testing.T) {
+	text := "ab\n€😀x\n"
+
+	offsets := []int{11, 0, 12, 6, 6, 99}
+	assert.Equal(t, []position{
+		{Line: 1, Character: 4},
+		{Line: 0, Character: 0},
+		{Line: 2, Character: 0},
+		{Line: 1, Character: 1},
+		{Line: 1, Character: 1},
+		{Line: 2, Character: 0},
+	}, positionsAt(text, offsets))
+
+	for _, offset := range offsets {
+		assert.Equal(t, positionAt(text, offset), positionsAt(text, []int{offset})[0])
+	}
+}
+
+func TestDocumentApply(t *testing.T) {
+	doc := document{text: "{\n  \"a\": 1\n}"}
+
+	doc.apply(contentChange{
+		Range: &textRange{Start: position{Line: 1, Character: 7}, End: position{Line: 1, Character: 8}},
+		Text:  "[true,\n false]",
+	})
+	assert.Equal(t, "{\n  \"a\": [true,\n false]\n}", doc.text)
+
+	doc.apply(contentChange{Text: "null"})
+	assert.Equal(t, "null", doc.text)
+}
+
+func TestFindCursorContext(t *testing.T) {
+	testCases := []struct {
+		name   string
+		text   string
+		offset int
+		path   []string
+		inKey  bool
+		key    string
+		start  int
+		end    int
+	}{
+		{"empty", ``, 0, []string{}, false, "", 0, 0},
+		{"root object", `{ `, 2, []string{}, true, "", 2, 2},
+		{"member value", `{"a": `, 6, []string{"a"}, false, "", 6, 6},
+		{"within key", `{"abc": 1}`, 3, []string{}, true, "abc", 1, 6},
+		{"unterminated key", `{"ab`, 4, []string{}, true, "ab", 1, 4},
+		{"within value", `{"a": "xyz"}`, 8, []string{"a"}, false, "", 6, 11},
+		{"within literal", `{"a": tru`, 8, []string{"a"}, false, "", 6, 9},
+		{"array elements", `{"a": [1, {"b": [`, 17, []string{"a", "1", "b", "0"}, false, "", 17, 17},
+		{"after closed", `{"a": {"b": 1}, `, 16, []string{}, true, "", 16, 16},
+		{"escaped quote", `{"a\"b": `, 9, []string{"a\"b"}, false, "", 9, 9},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := findCursorContext(tt.text, tt.offset)
+			assert.Equal(t, tt.path, ctx.path)
+			assert.Equal(t, tt.inKey, ctx.inKey)
+			assert.Equal(t, tt.key, ctx.key)
+			assert.Equal(t, tt.start, ctx.tokenStart)
+			assert.Equal(t, tt.end, ctx.tokenEnd)
+		})
+	}
+}
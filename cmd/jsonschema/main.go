@@ -0,0 +1,282 @@
+// Command jsonschema validates JSON documents against JSON Schemas.
+//
+// Usage:
+//
+//	jsonschema validate --schema schema.json [--ref other.json ...] [flags] [instance.json ...]
+//
+// Instances are read from each of the given files, or from standard input if no
+// files are given or if a file is named "-". With --jsonl, each non-blank line
+// of each input is validated as a separate instance, as soon as it is read.
+//
+// The exit status is 0 if every instance is valid, 1 if any instance is
+// invalid, and 2 if the schemas or instances could not be read or evaluated.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+
+	jsonschema "github.com/json-schema-spec/json-schema-go"
+)
+
+const (
+	exitValid   = 0
+	exitInvalid = 1
+	exitError   = 2
+)
+
+const usage = `usage: jsonschema validate --schema schema.json [--ref other.json ...] [flags] [instance.json ...]
+
+Validates each instance against schema.json. Instances are read from the given
+files, or from standard input if none are given or a file is named "-".
+
+flags:
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "validate" {
+		fmt.Fprint(stderr, usage)
+		return exitError
+	}
+
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+
+	var refs stringsFlag
+	schemaPath := flags.String("schema", "", "path to the schema to validate instances against")
+	flags.Var(&refs, "ref", "path to a schema referred to by other schemas; may be repeated")
+	output := flags.String("output", "text", `output format, either "text" or "json"`)
+	jsonl := flags.Bool("jsonl", false, "treat each line of input as a separate instance")
+	maxErrors := flags.Int("max-errors", 0, "maximum number of errors to report per instance; zero means no limit")
+	maxStackDepth := flags.Int("max-stack-depth", jsonschema.DefaultMaxStackDepth, "maximum number of $ref-s to follow without descending into an instance")
+	maxInstanceDepth := flags.Int("max-instance-depth", jsonschema.DefaultMaxInstanceDepth, "maximum depth of nesting to descend into within an instance; zero means no limit")
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return exitError
+	}
+
+	if *schemaPath == "" {
+		fmt.Fprintln(stderr, "jsonschema: --schema is required")
+		return exitError
+	}
+
+	var reporter reporter
+	switch *output {
+	case "text":
+		reporter = textReporter{w: stdout}
+	case "json":
+		enc := json.NewEncoder(stdout)
+		enc.SetEscapeHTML(false)
+		reporter = jsonReporter{enc: enc}
+	default:
+		fmt.Fprintf(stderr, "jsonschema: unknown output format: %q\n", *output)
+		return exitError
+	}
+
+	schema, err := readJSONFile(*schemaPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "jsonschema: %v\n", err)
+		return exitError
+	}
+
+	schemas := []jsonschema.Document{schema}
+	for _, path := range refs {
+		ref, err := readJSONFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "jsonschema: %v\n", err)
+			return exitError
+		}
+
+		schemas = append(schemas, ref)
+	}
+
+	uri, err := schemaURI(schema.Value)
+	if err != nil {
+		fmt.Fprintf(stderr, "jsonschema: %s: %v\n", *schemaPath, err)
+		return exitError
+	}
+
+	validator, err := jsonschema.NewValidatorFromDocumentsWithConfig(schemas, jsonschema.ValidatorConfig{
+		MaxErrors:        *maxErrors,
+		MaxStackDepth:    *maxStackDepth,
+		MaxInstanceDepth: *maxInstanceDepth,
+		CountAllErrors:   *maxErrors > 0,
+	})
+
+	if err != nil {
+		fmt.Fprintf(stderr, "jsonschema: compiling schemas: %v\n", err)
+		return exitError
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	status := exitValid
+	for _, path := range paths {
+		err := readInstances(path, stdin, *jsonl, func(instance instance) error {
+			result, err := validator.ValidateURIDocument(uri, instance.doc)
+			if err != nil {
+				return fmt.Errorf("%s: %v", instance.name, err)
+			}
+
+			if err := reporter.Report(instance, result); err != nil {
+				return err
+			}
+
+			if !result.IsValid() {
+				status = exitInvalid
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			fmt.Fprintf(stderr, "jsonschema: %v\n", err)
+			return exitError
+		}
+	}
+
+	return status
+}
+
+// stringsFlag is a flag which may be given many times.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// instance is a single document to be validated, along with a name identifying
+// where it came from.
+type instance struct {
+	name string
+	doc  jsonschema.Document
+
+	// file is the name of the file the instance came from, and line is the
+	// line of that file the instance begins on.
+	file string
+	line int
+}
+
+// location describes where in its file a position within the instance is. If
+// the position is not known, the name of the instance is used instead.
+func (i instance) location(pos jsonschema.Position) string {
+	if !pos.IsValid() {
+		return i.name
+	}
+
+	return fmt.Sprintf("%s:%d:%d", i.file, i.line+pos.Line-1, pos.Column)
+}
+
+func readJSONFile(path string) (jsonschema.Document, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return jsonschema.Document{}, err
+	}
+
+	doc, err := jsonschema.ParseJSON(data)
+	if err != nil {
+		return jsonschema.Document{}, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return doc, nil
+}
+
+// readInstances reads the instances in the file at path, calling fn with each
+// in turn. With jsonl, each line is passed to fn as soon as it is read, so
+// inputs need not fit in memory, and results for earlier lines are not held
+// back by later ones.
+func readInstances(path string, stdin io.Reader, jsonl bool, fn func(instance) error) error {
+	name := path
+	r := stdin
+
+	if path == "-" {
+		name = "<stdin>"
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+		r = f
+	}
+
+	if !jsonl {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+
+		doc, err := jsonschema.ParseJSON(data)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+
+		return fn(instance{name: name, doc: doc, file: name, line: 1})
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64*1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		lineName := fmt.Sprintf("%s:%d", name, line)
+		doc, err := jsonschema.ParseJSON(scanner.Bytes())
+		if err != nil {
+			return fmt.Errorf("%s: %v", lineName, err)
+		}
+
+		if err := fn(instance{name: lineName, doc: doc, file: name, line: line}); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// schemaURI returns the URI which identifies a schema to a Validator.
+func schemaURI(schema interface{}) (url.URL, error) {
+	object, ok := schema.(map[string]interface{})
+	if !ok {
+		return url.URL{}, nil
+	}
+
+	id, ok := object["$id"].(string)
+	if !ok {
+		return url.URL{}, nil
+	}
+
+	uri, err := url.Parse(id)
+	if err != nil {
+		return url.URL{}, err
+	}
+
+	return *uri, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jsonschema")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"schema.json": `{
+			"properties": {
+				"name": { "type": "string" },
+				"address": { "$ref": "http://example.com/address" }
+			}
+		}`,
+		"address.json": `{
+			"$id": "http://example.com/address",
+			"properties": { "zip": { "type": "string" } }
+		}`,
+		"valid.json":   `{ "name": "foo", "address": { "zip": "12345" } }`,
+		"invalid.json": `{ "name": 3, "address": { "zip": 12345 } }`,
+		"bad.json":     `{ "name": `,
+	}
+
+	for name, contents := range files {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644)
+		assert.NoError(t, err)
+	}
+
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+
+	testCases := []struct {
+		name   string
+		args   []string
+		stdin  string
+		status int
+		stdout string
+	}{
+		{
+			"no subcommand",
+			[]string{},
+			"",
+			exitError,
+			"",
+		},
+		{
+			"missing schema",
+			[]string{"validate", path("valid.json")},
+			"",
+			exitError,
+			"",
+		},
+		{
+			"missing ref",
+			[]string{"validate", "--schema", path("schema.json"), path("valid.json")},
+			"",
+			exitError,
+			"",
+		},
+		{
+			"valid instance",
+			[]string{"validate", "--schema", path("schema.json"), "--ref", path("address.json"), path("valid.json")},
+			"",
+			exitValid,
+			"",
+		},
+		{
+			"invalid instance",
+			[]string{"validate", "--schema", path("schema.json"), "--ref", path("address.json"), path("valid.json"), path("invalid.json")},
+			"",
+			exitInvalid,
+			path("invalid.json") + ":1:34: /address/zip: rejected by http://example.com/address#/properties/zip/type\n" +
+				path("invalid.json") + ":1:11: /name: rejected by #/properties/name/type\n",
+		},
+		{
+			"malformed instance",
+			[]string{"validate", "--schema", path("schema.json"), "--ref", path("address.json"), path("bad.json")},
+			"",
+			exitError,
+			"",
+		},
+		{
+			"stdin",
+			[]string{"validate", "--schema", path("schema.json"), "--ref", path("address.json")},
+			`{ "name": null }`,
+			exitInvalid,
+			"<stdin>:1:11: /name: rejected by #/properties/name/type\n",
+		},
+		{
+			"json lines",
+			[]string{"validate", "--schema", path("schema.json"), "--ref", path("address.json"), "--jsonl", "-"},
+			"{ \"name\": null }\n\n{ \"name\": \"foo\" }\n{ \"name\": 3 }\n",
+			exitInvalid,
+			"<stdin>:1:11: /name: rejected by #/properties/name/type\n" +
+				"<stdin>:4:11: /name: rejected by #/properties/name/type\n",
+		},
+		{
+			"json lines before malformed line",
+			[]string{"validate", "--schema", path("schema.json"), "--ref", path("address.json"), "--jsonl", "-"},
+			"{ \"name\": null }\n{ \"name\": \n{ \"name\": 3 }\n",
+			exitError,
+			"<stdin>:1:11: /name: rejected by #/properties/name/type\n",
+		},
+		{
+			"max errors",
+			[]string{"validate", "--schema", path("schema.json"), "--ref", path("address.json"), "--max-errors", "1", path("invalid.json")},
+			"",
+			exitInvalid,
+			path("invalid.json") + ":1:34: /address/zip: rejected by http://example.com/address#/properties/zip/type\n" +
+				path("invalid.json") + ": and 1 more errors\n",
+		},
+		{
+			"max instance depth",
+			[]string{"validate", "--schema", path("schema.json"), "--ref", path("address.json"), "--max-instance-depth", "1", path("valid.json")},
+			"",
+			exitError,
+			"",
+		},
+		{
+			"json output",
+			[]string{"validate", "--schema", path("schema.json"), "--ref", path("address.json"), "--output", "json", "-"},
+			`{ "name": null }`,
+			exitInvalid,
+			`{"instance":"<stdin>","valid":false,"errors":[{"instancePath":"/name","schemaPath":"/properties/name/type","uri":"","instanceStart":{"line":1,"column":11},"instanceEnd":{"line":1,"column":15},"schemaStart":{"line":3,"column":15}}],"errorCount":1,"overflowed":false}` + "\n",
+		},
+		{
+			"unknown output",
+			[]string{"validate", "--schema", path("schema.json"), "--output", "xml"},
+			"",
+			exitError,
+			"",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			status := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+
+			assert.Equal(t, tt.status, status, stderr.String())
+			assert.Equal(t, tt.stdout, stdout.String())
+		})
+	}
+}
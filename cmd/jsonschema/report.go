@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	jsonschema "github.com/json-schema-spec/json-schema-go"
+)
+
+// reporter writes out the result of validating an instance.
+type reporter interface {
+	Report(instance instance, result jsonschema.ValidationResult) error
+}
+
+// textReporter writes one line per validation error, prefixed with where the
+// rejected value begins.
+type textReporter struct {
+	w io.Writer
+}
+
+func (r textReporter) Report(instance instance, result jsonschema.ValidationResult) error {
+	for _, e := range result.Errors {
+		if _, err := fmt.Fprintf(r.w, "%s: %s: rejected by %s\n", instance.location(e.InstanceStart), displayPtr(e.InstancePath.String()), schemaLocation(e)); err != nil {
+			return err
+		}
+	}
+
+	if result.Overflowed {
+		more := result.ErrorCount - len(result.Errors)
+		if _, err := fmt.Fprintf(r.w, "%s: and %d more errors\n", instance.name, more); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// jsonReporter writes one JSON object per instance.
+type jsonReporter struct {
+	enc *json.Encoder
+}
+
+type jsonReport struct {
+	Instance   string      `json:"instance"`
+	Valid      bool        `json:"valid"`
+	Errors     []jsonError `json:"errors"`
+	ErrorCount int         `json:"errorCount"`
+	Overflowed bool        `json:"overflowed"`
+}
+
+type jsonError struct {
+	InstancePath  string        `json:"instancePath"`
+	SchemaPath    string        `json:"schemaPath"`
+	URI           string        `json:"uri"`
+	InstanceStart *jsonPosition `json:"instanceStart,omitempty"`
+	InstanceEnd   *jsonPosition `json:"instanceEnd,omitempty"`
+	SchemaStart   *jsonPosition `json:"schemaStart,omitempty"`
+	Duplicates    []int         `json:"duplicates,omitempty"`
+}
+
+// jsonPosition is a line and column in a file. Instance positions are lines of
+// the file the instance came from, even with --jsonl, and schema positions are
+// lines of the file the schema came from.
+type jsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (r jsonReporter) Report(instance instance, result jsonschema.ValidationResult) error {
+	errors := make([]jsonError, len(result.Errors))
+	for i, e := range result.Errors {
+		errors[i] = jsonError{
+			InstancePath:  e.InstancePath.String(),
+			SchemaPath:    e.SchemaPath.String(),
+			URI:           e.URI.String(),
+			InstanceStart: newJSONPosition(e.InstanceStart, instance.line),
+			InstanceEnd:   newJSONPosition(e.InstanceEnd, instance.line),
+			SchemaStart:   newJSONPosition(e.SchemaStart, 1),
+			Duplicates:    e.Duplicates,
+		}
+	}
+
+	return r.enc.Encode(jsonReport{
+		Instance:   instance.name,
+		Valid:      result.IsValid(),
+		Errors:     errors,
+		ErrorCount: result.ErrorCount,
+		Overflowed: result.Overflowed,
+	})
+}
+
+// newJSONPosition converts a position within a document that begins on the
+// given line of its file. Unknown positions are omitted.
+func newJSONPosition(pos jsonschema.Position, line int) *jsonPosition {
+	if !pos.IsValid() {
+		return nil
+	}
+
+	return &jsonPosition{Line: line + pos.Line - 1, Column: pos.Column}
+}
+
+// schemaLocation returns a URI identifying the part of a schema which
+// produced an error.
+func schemaLocation(e jsonschema.ValidationError) string {
+	uri := e.URI
+	uri.Fragment = e.SchemaPath.String()
+	return uri.String()
+}
+
+// displayPtr makes the JSON Pointer to the root of a document readable.
+func displayPtr(ptr string) string {
+	if ptr == "" {
+		return "(root)"
+	}
+
+	return ptr
+}
@@ -0,0 +1,228 @@
+package jsonschema
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+)
+
+// CoerceAndValidate is like Validate, but first converts parts of the instance
+// into the types the schema requires of them, much like the coerceTypes option
+// of Ajv. This is useful for data, such as query strings and form values,
+// in which everything is a string.
+//
+// Wherever a schema's "type" does not allow a value, the value is converted
+// into the first allowed type it can be converted into:
+//
+// Strings which are JSON numbers become numbers, or integers if they have no
+// fractional part. The strings "true" and "false" become booleans, and the
+// empty string becomes null.
+//
+// Values other than arrays and objects are wrapped into an array of one
+// element if the type requires an array.
+//
+// Values which cannot be converted are left as they are, and are then rejected
+// by validation as usual.
+//
+// Conversion follows "$ref", "allOf", "properties", "patternProperties",
+// "additionalProperties", "items" and "additionalItems". Other subschemas, such
+// as those of "anyOf" or "if", are not used, as whether they apply depends on
+// the instance being converted. Where several schemas apply to the same value,
+// their types are tried in turn.
+//
+// The coerced instance is returned along with the result of validating it. The
+// given instance is not modified.
+func (v *Validator) CoerceAndValidate(instance interface{}) (interface{}, ValidationResult, error) {
+	return v.CoerceAndValidateURI(url.URL{}, instance)
+}
+
+// CoerceAndValidateURI is like CoerceAndValidate, but evaluates the instance
+// against the schema identified by the given URI.
+//
+// See CoerceAndValidate for how instances are coerced, and ValidateURI for how
+// uri will be used.
+func (v *Validator) CoerceAndValidateURI(uri url.URL, instance interface{}) (interface{}, ValidationResult, error) {
+	return v.CoerceAndValidateURIContext(context.Background(), uri, instance)
+}
+
+// CoerceAndValidateURIContext is like CoerceAndValidateURI, but abandons
+// validation once ctx is done.
+//
+// See ValidateURIContext for how ctx will be used.
+func (v *Validator) CoerceAndValidateURIContext(ctx context.Context, uri url.URL, instance interface{}) (interface{}, ValidationResult, error) {
+	schema, ok := v.registry.Get(uri)
+	if !ok {
+		return nil, ValidationResult{}, ErrNoSuchSchema
+	}
+
+	c := coercer{
+		walker:         walker{registry: v.registry, maxStackDepth: v.config.MaxStackDepth},
+		integerEpsilon: v.config.IntegerEpsilon,
+	}
+
+	schemas, err := c.walker.Expand(nil, schema, 0)
+	if err != nil {
+		return nil, ValidationResult{}, err
+	}
+
+	coerced, err := c.Coerce(schemas, instance)
+	if err != nil {
+		return nil, ValidationResult{}, err
+	}
+
+	result, err := v.ValidateURIContext(ctx, uri, coerced)
+	if err != nil {
+		return nil, ValidationResult{}, err
+	}
+
+	return coerced, result, nil
+}
+
+// coercer converts parts of an instance into the types schemas require.
+type coercer struct {
+	walker         walker
+	integerEpsilon float64
+}
+
+// Coerce converts instance, and its elements or members, into the types the
+// given schemas require.
+func (c *coercer) Coerce(schemas []appliedSchema, instance interface{}) (interface{}, error) {
+	for _, s := range schemas {
+		if s.schema.Type.IsSet {
+			instance = c.coerceType(s.schema.Type, instance)
+		}
+	}
+
+	switch val := instance.(type) {
+	case []interface{}:
+		if !hasItemSchemas(schemas) {
+			return val, nil
+		}
+
+		elems := make([]interface{}, len(val))
+		for i, elem := range val {
+			itemSchemas, err := c.walker.ItemSchemas(schemas, i)
+			if err != nil {
+				return nil, err
+			}
+
+			elems[i], err = c.Coerce(itemSchemas, elem)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return elems, nil
+	case map[string]interface{}:
+		if !hasPropertySchemas(schemas) {
+			return val, nil
+		}
+
+		object := make(map[string]interface{}, len(val))
+		for _, key := range sortedKeys(val) {
+			propertySchemas, err := c.walker.PropertySchemas(schemas, key)
+			if err != nil {
+				return nil, err
+			}
+
+			object[key], err = c.Coerce(propertySchemas, val[key])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return object, nil
+	default:
+		return val, nil
+	}
+}
+
+// coerceType converts instance into one of the given types, if it is not
+// already one of them.
+func (c *coercer) coerceType(types schemaType, instance interface{}) interface{} {
+	if types.contains(c.typeOf(instance)) {
+		return instance
+	}
+
+	if _, ok := numberFloat(instance); ok && types.contains(jsonTypeNumber) {
+		// integers are numbers too, but typeOf only reports the narrower type
+		return instance
+	}
+
+	for _, typ := range types.Types {
+		switch typ {
+		case jsonTypeNumber, jsonTypeInteger:
+			s, ok := instance.(string)
+			if !ok || !isJSONNumber(s) {
+				continue
+			}
+
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				continue
+			}
+
+			if typ == jsonTypeInteger && !isInteger(f, c.integerEpsilon) {
+				continue
+			}
+
+			return f
+		case jsonTypeBoolean:
+			switch instance {
+			case "true":
+				return true
+			case "false":
+				return false
+			}
+		case jsonTypeNull:
+			if instance == "" {
+				return nil
+			}
+		case jsonTypeArray:
+			if _, ok := instance.(map[string]interface{}); !ok {
+				return []interface{}{instance}
+			}
+		}
+	}
+
+	return instance
+}
+
+// typeOf returns the narrowest type of a value. Numbers which are integers are
+// reported as integers.
+func (c *coercer) typeOf(instance interface{}) jsonType {
+	switch instance.(type) {
+	case nil:
+		return jsonTypeNull
+	case bool:
+		return jsonTypeBoolean
+	case string:
+		return jsonTypeString
+	case []interface{}:
+		return jsonTypeArray
+	case map[string]interface{}:
+		return jsonTypeObject
+	}
+
+	// numbers may be float64, or any other form numberFloat supports
+	if f, ok := numberFloat(instance); ok {
+		if isInteger(f, c.integerEpsilon) {
+			return jsonTypeInteger
+		}
+
+		return jsonTypeNumber
+	}
+
+	return jsonTypeObject
+}
+
+// isJSONNumber checks whether s is a number written as JSON would write it.
+// This excludes forms strconv.ParseFloat accepts, such as "Inf" or "0x1p3".
+func isJSONNumber(s string) bool {
+	if s == "" || (s[0] != '-' && (s[0] < '0' || s[0] > '9')) {
+		return false
+	}
+
+	return json.Valid([]byte(s))
+}
@@ -0,0 +1,283 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatorCoerceAndValidate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		schema   interface{}
+		instance interface{}
+		coerced  interface{}
+		valid    bool
+	}{
+		{
+			"integer",
+			map[string]interface{}{"type": "integer"},
+			"42",
+			float64(42),
+			true,
+		},
+		{
+			"non-integer for integer",
+			map[string]interface{}{"type": "integer"},
+			"4.5",
+			"4.5",
+			false,
+		},
+		{
+			"number",
+			map[string]interface{}{"type": "number"},
+			"-4.5e1",
+			float64(-45),
+			true,
+		},
+		{
+			"not a json number",
+			map[string]interface{}{"type": "number"},
+			"0x10",
+			"0x10",
+			false,
+		},
+		{
+			"padded number",
+			map[string]interface{}{"type": "number"},
+			" 1",
+			" 1",
+			false,
+		},
+		{
+			"boolean",
+			map[string]interface{}{"type": "boolean"},
+			"false",
+			false,
+			true,
+		},
+		{
+			"null",
+			map[string]interface{}{"type": "null"},
+			"",
+			nil,
+			true,
+		},
+		{
+			"first convertible type",
+			map[string]interface{}{"type": []interface{}{"null", "integer", "boolean"}},
+			"true",
+			true,
+			true,
+		},
+		{
+			"already allowed",
+			map[string]interface{}{"type": []interface{}{"string", "integer"}},
+			"42",
+			"42",
+			true,
+		},
+		{
+			"integer allowed as number",
+			map[string]interface{}{"type": "number"},
+			float64(3),
+			float64(3),
+			true,
+		},
+		{
+			"json.Number integer allowed",
+			map[string]interface{}{"type": "integer"},
+			json.Number("3"),
+			json.Number("3"),
+			true,
+		},
+		{
+			"json.Number integer allowed as number",
+			map[string]interface{}{"type": "number"},
+			json.Number("3"),
+			json.Number("3"),
+			true,
+		},
+		{
+			"json.Number wrapped into array",
+			map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "number"}},
+			json.Number("1.5"),
+			[]interface{}{json.Number("1.5")},
+			true,
+		},
+		{
+			"wrap into array",
+			map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}},
+			"3",
+			[]interface{}{float64(3)},
+			true,
+		},
+		{
+			"objects are not wrapped",
+			map[string]interface{}{"type": "array"},
+			map[string]interface{}{},
+			map[string]interface{}{},
+			false,
+		},
+		{
+			"tuple items",
+			map[string]interface{}{
+				"items":           []interface{}{map[string]interface{}{"type": "boolean"}},
+				"additionalItems": map[string]interface{}{"type": "number"},
+			},
+			[]interface{}{"true", "1.5", "x"},
+			[]interface{}{true, 1.5, "x"},
+			false,
+		},
+		{
+			"properties",
+			map[string]interface{}{
+				"properties": map[string]interface{}{
+					"age": map[string]interface{}{"type": "integer"},
+				},
+				"patternProperties": map[string]interface{}{
+					"^is": map[string]interface{}{"type": "boolean"},
+				},
+				"additionalProperties": map[string]interface{}{"type": "number"},
+			},
+			map[string]interface{}{"age": "42", "isAdmin": "true", "score": "9.5", "name": "x"},
+			map[string]interface{}{"age": float64(42), "isAdmin": true, "score": 9.5, "name": "x"},
+			false,
+		},
+		{
+			"ref and allOf",
+			map[string]interface{}{
+				"definitions": map[string]interface{}{
+					"age": map[string]interface{}{"type": "integer"},
+				},
+				"allOf": []interface{}{
+					map[string]interface{}{
+						"properties": map[string]interface{}{
+							"age": map[string]interface{}{"$ref": "#/definitions/age"},
+						},
+					},
+				},
+			},
+			map[string]interface{}{"age": "42"},
+			map[string]interface{}{"age": float64(42)},
+			true,
+		},
+		{
+			"anyOf not followed",
+			map[string]interface{}{
+				"anyOf": []interface{}{map[string]interface{}{"type": "integer"}},
+			},
+			"42",
+			"42",
+			false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			validator, err := NewValidator([]interface{}{tt.schema})
+			assert.NoError(t, err)
+
+			coerced, result, err := validator.CoerceAndValidate(tt.instance)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.coerced, coerced)
+			assert.Equal(t, tt.valid, result.IsValid())
+		})
+	}
+}
+
+func TestValidatorCoerceAndValidateCopies(t *testing.T) {
+	validator, err := NewValidator([]interface{}{
+		map[string]interface{}{
+			"items": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"n": map[string]interface{}{"type": "integer"},
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	instance := []interface{}{map[string]interface{}{"n": "1"}}
+	coerced, _, err := validator.CoerceAndValidate(instance)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{map[string]interface{}{"n": float64(1)}}, coerced)
+	assert.Equal(t, []interface{}{map[string]interface{}{"n": "1"}}, instance)
+}
+
+func TestValidatorCoerceAndValidateErrors(t *testing.T) {
+	validator, err := NewValidatorWithConfig([]interface{}{
+		map[string]interface{}{
+			"definitions": map[string]interface{}{
+				"a": map[string]interface{}{"$ref": "#/definitions/b"},
+				"b": map[string]interface{}{"$ref": "#/definitions/c"},
+				"c": map[string]interface{}{"$ref": "#/definitions/d"},
+				"d": map[string]interface{}{},
+			},
+			"$ref": "#/definitions/a",
+		},
+	}, ValidatorConfig{MaxStackDepth: 3})
+	assert.NoError(t, err)
+
+	_, _, err = validator.CoerceAndValidate(nil)
+	assert.Equal(t, ErrStackOverflow, err)
+
+	_, _, err = validator.CoerceAndValidateURI(url.URL{Host: "example.com"}, nil)
+	assert.Equal(t, ErrNoSuchSchema, err)
+}
+
+func TestCoercerTypeOf(t *testing.T) {
+	testCases := []struct {
+		instance interface{}
+		typ      jsonType
+	}{
+		{nil, jsonTypeNull},
+		{true, jsonTypeBoolean},
+		{float64(3), jsonTypeInteger},
+		{float64(3.5), jsonTypeNumber},
+		{json.Number("3"), jsonTypeInteger},
+		{json.Number("3.5"), jsonTypeNumber},
+		{json.Number("1e400"), jsonTypeNumber},
+		{int(3), jsonTypeInteger},
+		{int64(3), jsonTypeInteger},
+		{"3", jsonTypeString},
+		{[]interface{}{}, jsonTypeArray},
+		{map[string]interface{}{}, jsonTypeObject},
+	}
+
+	c := coercer{integerEpsilon: DefaultEpsilon}
+	for _, tt := range testCases {
+		assert.Equal(t, tt.typ, c.typeOf(tt.instance), "%#v", tt.instance)
+	}
+}
+
+func TestCoercerCoerceTypeNumber(t *testing.T) {
+	testCases := []struct {
+		value string
+		out   interface{}
+	}{
+		{"10", 10.0},
+		{"-1.5e2", -150.0},
+		{"0", 0.0},
+		{"NaN", "NaN"},
+		{"Inf", "Inf"},
+		{"-Inf", "-Inf"},
+		{"Infinity", "Infinity"},
+		{"0x1p3", "0x1p3"},
+		{"1e400", "1e400"},
+		{"+1", "+1"},
+		{"01", "01"},
+		{"1_000", "1_000"},
+		{" 1", " 1"},
+		{"1 ", "1 "},
+		{"", ""},
+	}
+
+	c := coercer{integerEpsilon: DefaultEpsilon}
+	types := schemaType{IsSet: true, IsSingle: true, Types: []jsonType{jsonTypeNumber}}
+	for _, tt := range testCases {
+		assert.Equal(t, tt.out, c.coerceType(types, tt.value), "%q", tt.value)
+	}
+}
@@ -0,0 +1,358 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// equalJSON determines whether a and b are the same JSON value, as "const",
+// "enum" and "uniqueItems" require.
+//
+// Unlike reflect.DeepEqual, numbers are equal if they have the same value,
+// however they are represented. A float64 is taken to have the value of its
+// shortest decimal representation, which is how it would be written in a JSON
+// document, so float64(0.1) equals json.Number("0.1") and json.Number("1.0")
+// equals float64(1). Numbers too large to be a float64 are equal only if they
+// are written the same way.
+func equalJSON(a, b interface{}) bool {
+	switch a := a.(type) {
+	case nil:
+		return b == nil
+	case bool:
+		b, ok := b.(bool)
+		return ok && a == b
+	case string:
+		b, ok := b.(string)
+		return ok && a == b
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+
+		for i := range a {
+			if !equalJSON(a[i], b[i]) {
+				return false
+			}
+		}
+
+		return true
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+
+		for key, value := range a {
+			other, ok := b[key]
+			if !ok || !equalJSON(value, other) {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return equalNumbers(a, b)
+	}
+}
+
+// equalNumbers determines whether a and b are numbers with the same value.
+func equalNumbers(a, b interface{}) bool {
+	fa, ok := numberFloat(a)
+	if !ok {
+		return false
+	}
+
+	fb, ok := numberFloat(b)
+	if !ok {
+		return false
+	}
+
+	_, aIsFloat := a.(float64)
+	_, bIsFloat := b.(float64)
+	if aIsFloat && bIsFloat {
+		return fa == fb
+	}
+
+	if fa != fb {
+		// numbers which are not even close enough to round to the same float64
+		// cannot be equal
+		return false
+	}
+
+	if math.IsInf(fa, 0) {
+		// too large to compare exactly without risking enormous allocations
+		return numberString(a) == numberString(b)
+	}
+
+	ca, ok := canonicalNumber(a)
+	if !ok {
+		return false
+	}
+
+	cb, ok := canonicalNumber(b)
+	if !ok {
+		return false
+	}
+
+	return ca == cb
+}
+
+// numberFloat returns the float64 nearest to a number, which may be a
+// float64, a json.Number, or an int or int64 as some decoders produce.
+func numberFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil && !isRangeError(err) {
+			return 0, false
+		}
+
+		return f, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+// numberString returns a number as it would be written in a JSON document.
+func numberString(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case json.Number:
+		return string(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	default:
+		return ""
+	}
+}
+
+// canonicalNumber returns the value of a number written in a canonical form,
+// such that two numbers have the same value exactly when they have the same
+// canonical form. See numberFloat for the numbers supported.
+//
+// The form is the sign, then the significant digits without leading or
+// trailing zeros, then the exponent which makes those digits a fraction less
+// than one: 120, 1.2e2 and 0.00012e6 are all "12e3", and -0.5 is "-5e0". Zero,
+// however it is written, is "0".
+func canonicalNumber(v interface{}) (string, bool) {
+	s := numberString(v)
+
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+
+	mantissa, exponent := s, "0"
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mantissa, exponent = s[:i], s[i+1:]
+	}
+
+	intPart, fracPart := mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		intPart, fracPart = mantissa[:i], mantissa[i+1:]
+		if fracPart == "" {
+			return "", false
+		}
+	}
+
+	unsignedExponent := strings.TrimLeft(exponent, "+-")
+	if len(exponent)-len(unsignedExponent) > 1 || !isDigits(intPart) || !isDigits(unsignedExponent) || (fracPart != "" && !isDigits(fracPart)) {
+		return "", false
+	}
+
+	digits := strings.TrimLeft(intPart+fracPart, "0")
+	shift := len(digits) - len(fracPart)
+	digits = strings.TrimRight(digits, "0")
+	if digits == "" {
+		return "0", true
+	}
+
+	var b strings.Builder
+	if neg {
+		b.WriteByte('-')
+	}
+
+	b.WriteString(digits)
+	b.WriteByte('e')
+
+	// exponents are only too large for an int64 in numbers which round to zero
+	// or infinity as a float64, which are rare enough to be worth no more than
+	// falling back to big.Int
+	e, err := strconv.ParseInt(exponent, 10, 64)
+	if err != nil || e > math.MaxInt64/2 || e < math.MinInt64/2 {
+		n, _ := new(big.Int).SetString(exponent, 10)
+		b.WriteString(n.Add(n, big.NewInt(int64(shift))).String())
+	} else {
+		b.WriteString(strconv.FormatInt(e+int64(shift), 10))
+	}
+
+	return b.String(), true
+}
+
+// isDigits checks whether s is a non-empty string of decimal digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isRangeError(err error) bool {
+	numErr, ok := err.(*strconv.NumError)
+	return ok && numErr.Err == strconv.ErrRange
+}
+
+// FNV-1a parameters, used by hashJSON.
+const (
+	fnvOffset64 uint64 = 14695981039346656037
+	fnvPrime64  uint64 = 1099511628211
+)
+
+// Tags distinguishing the kinds of value hashed by hashJSON, so that, for
+// instance, "1" and 1 hash differently.
+const (
+	hashTagNull byte = iota + 1
+	hashTagFalse
+	hashTagTrue
+	hashTagNumber
+	hashTagString
+	hashTagArray
+	hashTagObject
+)
+
+// hashJSON returns a hash of a JSON value. Values which equalJSON considers
+// equal have the same hash.
+func hashJSON(v interface{}) uint64 {
+	return hashValue(fnvOffset64, v)
+}
+
+func hashValue(h uint64, v interface{}) uint64 {
+	switch v := v.(type) {
+	case nil:
+		return hashByte(h, hashTagNull)
+	case bool:
+		if v {
+			return hashByte(h, hashTagTrue)
+		}
+
+		return hashByte(h, hashTagFalse)
+	case string:
+		return hashString(hashByte(h, hashTagString), v)
+	case []interface{}:
+		h = hashUint64(hashByte(h, hashTagArray), uint64(len(v)))
+		for _, elem := range v {
+			h = hashValue(h, elem)
+		}
+
+		return h
+	case map[string]interface{}:
+		// members are combined by addition, so that their order does not matter
+		var sum uint64
+		for key, value := range v {
+			sum += hashValue(hashString(fnvOffset64, key), value)
+		}
+
+		h = hashUint64(hashByte(h, hashTagObject), uint64(len(v)))
+		return hashUint64(h, sum)
+	default:
+		// many distinct numbers round to the same float64, so rather than
+		// that, the canonical form of the number is hashed
+		c, _ := canonicalNumber(v)
+		return hashString(hashByte(h, hashTagNumber), c)
+	}
+}
+
+func hashByte(h uint64, b byte) uint64 {
+	return (h ^ uint64(b)) * fnvPrime64
+}
+
+func hashUint64(h uint64, n uint64) uint64 {
+	for i := 0; i < 8; i++ {
+		h = hashByte(h, byte(n>>uint(8*i)))
+	}
+
+	return h
+}
+
+// hashString hashes s along with its length, so that the strings within an
+// array cannot run together.
+func hashString(h uint64, s string) uint64 {
+	h = hashUint64(h, uint64(len(s)))
+	for i := 0; i < len(s); i++ {
+		h = hashByte(h, s[i])
+	}
+
+	return h
+}
+
+// smallUniqueItems is the length up to which arrays are checked for
+// "uniqueItems" by comparing every pair of elements, which for short arrays is
+// faster than hashing and allocates nothing.
+const smallUniqueItems = 16
+
+// findDuplicate finds two equal elements of an array, returning the indices of
+// the pair whose later element comes first, and of the earliest element equal
+// to it.
+func (vm *vm) findDuplicate(elems []interface{}) (int, int, bool, error) {
+	if len(elems) <= smallUniqueItems {
+		for j := 1; j < len(elems); j++ {
+			for i := 0; i < j; i++ {
+				if equalJSON(elems[i], elems[j]) {
+					return i, j, true, nil
+				}
+			}
+		}
+
+		return 0, 0, false, nil
+	}
+
+	// latest holds the index of the latest element with each hash, and prev the
+	// index of the element before it with the same hash, or -1
+	latest := make(map[uint64]int, len(elems))
+	prev := make([]int, len(elems))
+
+	for j, elem := range elems {
+		if err := vm.checkContext(); err != nil {
+			return 0, 0, false, err
+		}
+
+		h := hashJSON(elem)
+		i, ok := latest[h]
+		if !ok {
+			i = -1
+		}
+
+		prev[j] = i
+		latest[h] = j
+
+		// no two elements before j are equal, or j would not have been reached,
+		// so the first equal element found is the only one
+		for ; i >= 0; i = prev[i] {
+			if equalJSON(elems[i], elem) {
+				return i, j, true, nil
+			}
+		}
+	}
+
+	return 0, 0, false, nil
+}
@@ -0,0 +1,242 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEqualJSON(t *testing.T) {
+	testCases := []struct {
+		a     interface{}
+		b     interface{}
+		equal bool
+	}{
+		{nil, nil, true},
+		{nil, false, false},
+		{true, true, true},
+		{true, false, false},
+		{"a", "a", true},
+		{"a", "b", false},
+		{"1", 1.0, false},
+		{1.0, 1.0, true},
+		{0.0, -0.0, true},
+		{1.0, json.Number("1"), true},
+		{1.0, json.Number("1.0"), true},
+		{1.0, json.Number("1e0"), true},
+		{0.1, json.Number("0.1"), true},
+		{0.1, json.Number("0.10000000000000001"), false},
+		{json.Number("0.1"), json.Number("1e-1"), true},
+		{json.Number("9007199254740993"), json.Number("9007199254740992"), false},
+		{json.Number("9007199254740993"), int64(9007199254740993), true},
+		{json.Number("1e400"), json.Number("1e400"), true},
+		{json.Number("1e400"), json.Number("10e399"), false},
+		{json.Number("1e-400"), json.Number("2e-400"), false},
+		{json.Number("1e-400"), json.Number("0.1e-399"), true},
+		{json.Number("0"), json.Number("-0.0e5"), true},
+		{json.Number("1.0000000000000000001"), 1.0, false},
+		{json.Number("120"), json.Number("1.2e2"), true},
+		{json.Number("1e-99999999999999999999"), json.Number("1e-99999999999999999999"), true},
+		{json.Number("1e-99999999999999999999"), json.Number("2e-99999999999999999999"), false},
+		{1, 1.0, true},
+		{int64(2), json.Number("2.00"), true},
+		{json.Number("abc"), json.Number("abc"), false},
+		{[]interface{}{1.0, "a"}, []interface{}{json.Number("1"), "a"}, true},
+		{[]interface{}{1.0, "a"}, []interface{}{"a", 1.0}, false},
+		{[]interface{}{1.0}, []interface{}{1.0, 1.0}, false},
+		{
+			map[string]interface{}{"a": 1.0, "b": []interface{}{nil}},
+			map[string]interface{}{"b": []interface{}{nil}, "a": json.Number("1")},
+			true,
+		},
+		{
+			map[string]interface{}{"a": 1.0},
+			map[string]interface{}{"b": 1.0},
+			false,
+		},
+		{
+			map[string]interface{}{"a": 1.0},
+			map[string]interface{}{"a": 1.0, "b": 1.0},
+			false,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(fmt.Sprintf("%#v %#v", tt.a, tt.b), func(t *testing.T) {
+			assert.Equal(t, tt.equal, equalJSON(tt.a, tt.b))
+			assert.Equal(t, tt.equal, equalJSON(tt.b, tt.a))
+
+			if tt.equal {
+				assert.Equal(t, hashJSON(tt.a), hashJSON(tt.b))
+			}
+		})
+	}
+}
+
+func TestHashJSON(t *testing.T) {
+	// values which are not equal should rarely collide
+	values := []interface{}{
+		nil, true, false, 0.0, 1.0, "", "0", "1", "ab",
+		[]interface{}{}, []interface{}{"a", "b"}, []interface{}{"ab"}, []interface{}{"a", "b", "c"},
+		map[string]interface{}{}, map[string]interface{}{"a": "b"}, map[string]interface{}{"ab": ""},
+		map[string]interface{}{"a": "b", "c": "d"}, map[string]interface{}{"a": "d", "c": "b"},
+
+		// numbers which round to the same float64, but are not equal
+		json.Number("1e-400"), json.Number("2e-400"), json.Number("1.0000000000000000001"),
+	}
+
+	hashes := map[uint64]interface{}{}
+	for _, value := range values {
+		h := hashJSON(value)
+		other, ok := hashes[h]
+		assert.False(t, ok, "%#v and %#v collide", value, other)
+		hashes[h] = value
+	}
+}
+
+func TestCanonicalNumber(t *testing.T) {
+	testCases := []struct {
+		number    interface{}
+		canonical string
+		ok        bool
+	}{
+		{0.0, "0", true},
+		{-0.0, "0", true},
+		{json.Number("-0.000e7"), "0", true},
+		{1.0, "1e1", true},
+		{120.0, "12e3", true},
+		{json.Number("1.2e2"), "12e3", true},
+		{json.Number("0.00012E+6"), "12e3", true},
+		{-0.5, "-5e0", true},
+		{0.001, "1e-2", true},
+		{1e21, "1e22", true},
+		{json.Number("1e-400"), "1e-399", true},
+		{json.Number("1e9223372036854775807"), "1e9223372036854775808", true},
+		{int64(-9007199254740993), "-9007199254740993e16", true},
+		{7, "7e1", true},
+		{json.Number("abc"), "", false},
+		{json.Number("1."), "", false},
+		{json.Number(".5"), "", false},
+		{json.Number("1e"), "", false},
+		{json.Number("1e+-2"), "", false},
+		{"1", "", false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(fmt.Sprintf("%#v", tt.number), func(t *testing.T) {
+			canonical, ok := canonicalNumber(tt.number)
+			assert.Equal(t, tt.canonical, canonical)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
+
+func TestValidatorUniqueItemsLarge(t *testing.T) {
+	validator, err := NewValidator([]interface{}{
+		map[string]interface{}{"uniqueItems": true},
+	})
+	assert.NoError(t, err)
+
+	instance := make([]interface{}, 50000)
+	for i := range instance {
+		instance[i] = map[string]interface{}{"id": float64(i)}
+	}
+
+	result, err := validator.Validate(instance)
+	assert.NoError(t, err)
+	assert.True(t, result.IsValid())
+
+	instance = append(instance, map[string]interface{}{"id": json.Number("1234")})
+
+	result, err = validator.Validate(instance)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, []int{1234, 50000}, result.Errors[0].Duplicates)
+
+	valid, err := validator.IsValid(instance)
+	assert.NoError(t, err)
+	assert.False(t, valid)
+}
+
+func TestValidatorUniqueItemsRoundingNumbers(t *testing.T) {
+	validator, err := NewValidator([]interface{}{
+		map[string]interface{}{"uniqueItems": true},
+	})
+	assert.NoError(t, err)
+
+	// these all round to zero as a float64, but are distinct, and hash so
+	instance := make([]interface{}, 20000)
+	for i := range instance {
+		instance[i] = json.Number(strconv.Itoa(i+1) + "e-400")
+	}
+
+	result, err := validator.Validate(instance)
+	assert.NoError(t, err)
+	assert.True(t, result.IsValid())
+
+	instance = append(instance, json.Number("0.1234e-396"))
+
+	result, err = validator.Validate(instance)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, []int{1233, 20000}, result.Errors[0].Duplicates)
+}
+
+func TestValidatorJSONNumber(t *testing.T) {
+	validator, err := NewValidator([]interface{}{
+		map[string]interface{}{
+			"properties": map[string]interface{}{
+				"version": map[string]interface{}{"const": json.Number("2.0")},
+				"count":   map[string]interface{}{"type": "integer", "minimum": 1.0},
+				"unit":    map[string]interface{}{"enum": []interface{}{json.Number("1"), json.Number("1000")}},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	result, err := validator.Validate(map[string]interface{}{
+		"version": 2.0,
+		"count":   json.Number("3"),
+		"unit":    1000.0,
+	})
+	assert.NoError(t, err)
+	assert.True(t, result.IsValid())
+
+	result, err = validator.Validate(map[string]interface{}{
+		"version": json.Number("2.5"),
+		"count":   json.Number("0.5"),
+		"unit":    json.Number("10"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(result.Errors))
+}
+
+func TestSchemaEnum(t *testing.T) {
+	values := []interface{}{}
+	for i := 0; i < 100; i++ {
+		values = append(values, json.Number(strconv.Itoa(i)))
+	}
+
+	values = append(values, map[string]interface{}{"a": 1.0, "b": 2.0})
+
+	small := newSchemaEnum(values[:smallEnum])
+	assert.Nil(t, small.Hashes)
+
+	large := newSchemaEnum(values)
+	assert.NotNil(t, large.Hashes)
+
+	for _, e := range []schemaEnum{small, large} {
+		assert.True(t, e.contains(0.0))
+		assert.True(t, e.contains(json.Number("7.0")))
+		assert.False(t, e.contains(-1.0))
+		assert.False(t, e.contains("7"))
+	}
+
+	assert.True(t, large.contains(99.0))
+	assert.True(t, large.contains(map[string]interface{}{"b": json.Number("2"), "a": 1.0}))
+	assert.False(t, large.contains(map[string]interface{}{"a": 1.0}))
+	assert.False(t, large.contains(100.0))
+}
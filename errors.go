@@ -4,11 +4,15 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"strings"
 )
 
 // ErrStackOverflow indicates that the evaluator overflowed its internal stack
-// while evaluating a schema. This can arise from schemas that have cyclical
-// definitions using the "$ref" keyword.
+// while evaluating a schema, by following more than MaxStackDepth "$ref"s
+// without descending into the instance. This can arise from schemas that have
+// cyclical definitions using the "$ref" keyword which are only followed for
+// some instances. Cycles which would be followed for every instance are
+// instead rejected with ErrRefCycle.
 var ErrStackOverflow = errors.New("stack overflow evaluating schema")
 
 // ErrInvalidSchema indicates that an inputted schema was invalid.
@@ -29,3 +33,93 @@ type ErrMissingURIs struct {
 func (e ErrMissingURIs) Error() string {
 	return fmt.Sprintf("missing schemas with URIs: %v", e.URIs)
 }
+
+// ErrRefCycle indicates that some schemas refer to one another in a cycle that
+// evaluation would follow forever, such as {"$ref": "#"}, because nothing in
+// the cycle descends into the instance.
+type ErrRefCycle struct {
+	// URIs lists the URIs of the "$ref"s making up the cycle, in the order
+	// they would be followed.
+	URIs []url.URL
+}
+
+// Error fulfills the error interface.
+func (e ErrRefCycle) Error() string {
+	uris := make([]string, len(e.URIs))
+	for i, uri := range e.URIs {
+		uris[i] = uri.String()
+		if uri.Fragment == "" {
+			uris[i] += "#"
+		}
+	}
+
+	return fmt.Sprintf("cyclical $ref: %s -> %s", strings.Join(uris, " -> "), uris[0])
+}
+
+// Budget identifies one of the resource limits that can be placed on
+// evaluation through ValidatorConfig.
+type Budget int
+
+const (
+	// BudgetInstanceDepth corresponds to ValidatorConfig.MaxInstanceDepth.
+	BudgetInstanceDepth Budget = iota + 1
+
+	// BudgetNodes corresponds to ValidatorConfig.MaxNodes.
+	BudgetNodes
+
+	// BudgetFuel corresponds to ValidatorConfig.MaxFuel.
+	BudgetFuel
+
+	// BudgetStringLength corresponds to ValidatorConfig.MaxStringLength.
+	BudgetStringLength
+
+	// BudgetArrayLength corresponds to ValidatorConfig.MaxArrayLength.
+	BudgetArrayLength
+)
+
+// String fulfills the fmt.Stringer interface.
+func (b Budget) String() string {
+	switch b {
+	case BudgetInstanceDepth:
+		return "instance depth"
+	case BudgetNodes:
+		return "nodes"
+	case BudgetFuel:
+		return "fuel"
+	case BudgetStringLength:
+		return "string length"
+	case BudgetArrayLength:
+		return "array length"
+	default:
+		return fmt.Sprintf("Budget(%d)", int(b))
+	}
+}
+
+// ErrBudgetExceeded indicates that evaluating an instance went over one of the
+// resource limits configured in ValidatorConfig.
+type ErrBudgetExceeded struct {
+	// Budget is the limit which was exceeded.
+	Budget Budget
+
+	// Limit is the configured value of the limit which was exceeded.
+	Limit int
+}
+
+// Error fulfills the error interface.
+func (e ErrBudgetExceeded) Error() string {
+	return fmt.Sprintf("budget exceeded: %v (limit %d)", e.Budget, e.Limit)
+}
+
+// ErrSyntax indicates that ParseJSON was given malformed JSON.
+type ErrSyntax struct {
+	// Message describes what was wrong with the JSON.
+	Message string
+
+	// Position is where in the input the problem was found.
+	Position Position
+}
+
+// Error fulfills the error interface.
+func (e ErrSyntax) Error() string {
+	return fmt.Sprintf("json: %s at %v", e.Message, e.Position)
+}
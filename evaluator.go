@@ -0,0 +1,64 @@
+package jsonschema
+
+import (
+	"context"
+	"net/url"
+)
+
+// Evaluator evaluates instances against the schemas of a Validator, reusing
+// the storage it allocates from one evaluation to the next. Once it has grown
+// to fit the instances it sees, evaluating a valid instance allocates nothing.
+//
+// Validators already reuse storage internally, but an Evaluator avoids even
+// the small cost of doing so safely across goroutines. Unlike a Validator, an
+// Evaluator is not safe for concurrent use; use one Evaluator per goroutine.
+type Evaluator struct {
+	validator *Validator
+	vm        vm
+}
+
+// NewEvaluator returns an Evaluator for the schemas of the Validator.
+func (v *Validator) NewEvaluator() *Evaluator {
+	return &Evaluator{
+		validator: v,
+		vm:        newVM(context.Background(), v.registry, v.program, v.config),
+	}
+}
+
+// Validate is like Validator.Validate.
+func (e *Evaluator) Validate(instance interface{}) (ValidationResult, error) {
+	return e.ValidateURIContext(context.Background(), url.URL{}, instance)
+}
+
+// ValidateURI is like Validator.ValidateURI.
+func (e *Evaluator) ValidateURI(uri url.URL, instance interface{}) (ValidationResult, error) {
+	return e.ValidateURIContext(context.Background(), uri, instance)
+}
+
+// IsValid is like Validator.IsValid.
+func (e *Evaluator) IsValid(instance interface{}) (bool, error) {
+	return e.IsValidURIContext(context.Background(), url.URL{}, instance)
+}
+
+// IsValidURIContext is like Validator.IsValidURIContext.
+func (e *Evaluator) IsValidURIContext(ctx context.Context, uri url.URL, instance interface{}) (bool, error) {
+	e.vm.reset(ctx)
+	defer e.release()
+
+	return e.vm.IsValid(uri, instance)
+}
+
+// ValidateURIContext is like Validator.ValidateURIContext.
+func (e *Evaluator) ValidateURIContext(ctx context.Context, uri url.URL, instance interface{}) (ValidationResult, error) {
+	e.vm.reset(ctx)
+	defer e.release()
+
+	return e.validator.exec(&e.vm, uri, instance)
+}
+
+// release drops the context of the last evaluation, so that the evaluator does
+// not keep it alive.
+func (e *Evaluator) release() {
+	e.vm.ctx = nil
+	e.vm.done = nil
+}
@@ -0,0 +1,111 @@
+package jsonschema
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluator(t *testing.T) {
+	validator, err := NewValidator([]interface{}{
+		map[string]interface{}{
+			"$id":  "http://example.com/name",
+			"type": "string",
+		},
+		map[string]interface{}{
+			"items": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"name": map[string]interface{}{"$ref": "http://example.com/name"},
+				},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	evaluator := validator.NewEvaluator()
+	instances := []interface{}{
+		[]interface{}{map[string]interface{}{"name": "a"}},
+		[]interface{}{map[string]interface{}{"name": 1.0}, map[string]interface{}{"name": nil}},
+		[]interface{}{},
+	}
+
+	for _, instance := range instances {
+		expected, err := validator.Validate(instance)
+		assert.NoError(t, err)
+
+		actual, err := evaluator.Validate(instance)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	}
+
+	valid, err := evaluator.IsValid(instances[1])
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	valid, err = evaluator.IsValid(instances[0])
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	result, err := evaluator.ValidateURI(url.URL{Scheme: "http", Host: "example.com", Path: "/name"}, 1.0)
+	assert.NoError(t, err)
+	assert.False(t, result.IsValid())
+
+	_, err = evaluator.ValidateURI(url.URL{Host: "example.com"}, nil)
+	assert.Equal(t, ErrNoSuchSchema, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = evaluator.ValidateURIContext(ctx, url.URL{}, nil)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestEvaluatorAllocs(t *testing.T) {
+	validator, err := NewValidator([]interface{}{
+		map[string]interface{}{
+			"definitions": map[string]interface{}{
+				"tag": map[string]interface{}{
+					"anyOf": []interface{}{
+						map[string]interface{}{"type": "integer"},
+						map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+			"properties": map[string]interface{}{
+				"tags": map[string]interface{}{
+					"items": map[string]interface{}{"$ref": "#/definitions/tag"},
+				},
+				"point": map[string]interface{}{
+					"items": []interface{}{
+						map[string]interface{}{"type": "number"},
+						map[string]interface{}{"type": "number"},
+					},
+					"additionalItems": false,
+				},
+				"metadata": map[string]interface{}{
+					"propertyNames":        map[string]interface{}{"pattern": "^[a-z]+$"},
+					"additionalProperties": map[string]interface{}{"not": map[string]interface{}{"type": "object"}},
+				},
+			},
+			"required": []interface{}{"tags"},
+		},
+	})
+	assert.NoError(t, err)
+
+	instance := map[string]interface{}{
+		"tags":     []interface{}{"a", 1.0, "b"},
+		"point":    []interface{}{1.0, 2.0},
+		"metadata": map[string]interface{}{"source": "import", "batch": 7.0},
+	}
+
+	evaluator := validator.NewEvaluator()
+	allocs := testing.AllocsPerRun(100, func() {
+		result, err := evaluator.Validate(instance)
+		assert.NoError(t, err)
+		assert.True(t, result.IsValid())
+	})
+
+	assert.Equal(t, 0.0, allocs)
+}
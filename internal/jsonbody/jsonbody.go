@@ -0,0 +1,43 @@
+// Package jsonbody decodes the JSON bodies of HTTP requests and responses, for
+// the middleware and openapi packages.
+package jsonbody
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"strings"
+)
+
+// IsJSON checks whether a Content-Type header refers to JSON. A missing
+// header is taken to mean JSON.
+func IsJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// Decode decodes exactly one JSON value from data.
+func Decode(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+
+	return value, nil
+}
@@ -0,0 +1,55 @@
+package jsonbody
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsJSON(t *testing.T) {
+	testCases := []struct {
+		contentType string
+		ok          bool
+	}{
+		{"", true},
+		{"application/json", true},
+		{"application/json; charset=utf-8", true},
+		{"Application/JSON", true},
+		{"application/problem+json", true},
+		{"text/plain", false},
+		{"application/jsonx", false},
+		{"application/json; charset", false},
+	}
+
+	for _, tt := range testCases {
+		assert.Equal(t, tt.ok, IsJSON(tt.contentType), "%q", tt.contentType)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	testCases := []struct {
+		data  string
+		value interface{}
+		err   string
+	}{
+		{`{"a": [1]}`, map[string]interface{}{"a": []interface{}{1.0}}, ""},
+		{` null `, nil, ""},
+		{`{"a": `, nil, "unexpected EOF"},
+		{`1 2`, nil, "unexpected data after JSON value"},
+		{`{} {}`, nil, "unexpected data after JSON value"},
+		{``, nil, "EOF"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.data, func(t *testing.T) {
+			value, err := Decode([]byte(tt.data))
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.value, value)
+		})
+	}
+}
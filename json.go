@@ -0,0 +1,326 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// maxJSONDepth is the deepest nesting of arrays and objects ParseJSON will
+// accept. It matches the limit encoding/json imposes.
+const maxJSONDepth = 10000
+
+// ParseJSON parses a JSON document, recording where each value within it
+// appeared.
+//
+// If data is not valid JSON, an instance of ErrSyntax is returned.
+//
+// The value of the returned Document is the same as encoding/json would
+// produce when decoding data into an interface{}. As with encoding/json, if an
+// object has duplicate keys, the last one is used.
+func ParseJSON(data []byte) (Document, error) {
+	p := jsonParser{
+		data:  data,
+		lines: newLineIndex(data),
+	}
+
+	root := &sourceNode{}
+	p.skipWhitespace()
+	value, err := p.parseValue(root)
+	if err != nil {
+		return Document{}, err
+	}
+
+	p.skipWhitespace()
+	if p.offset < len(p.data) {
+		return Document{}, p.errorf("unexpected %q after top-level value", p.data[p.offset])
+	}
+
+	return Document{Value: value, sourceMap: sourceMap{root: root}}, nil
+}
+
+// jsonParser is a recursive-descent JSON parser which records the position of
+// each value it parses.
+type jsonParser struct {
+	data   []byte
+	offset int
+	lines  lineIndex
+	depth  int
+}
+
+// parseValue parses the value at the current offset, recording where it
+// appeared in node.
+func (p *jsonParser) parseValue(node *sourceNode) (interface{}, error) {
+	if p.depth > maxJSONDepth {
+		return nil, p.errorf("exceeded max depth")
+	}
+
+	node.start = p.lines.Position(p.offset)
+
+	var value interface{}
+	var err error
+
+	if p.offset >= len(p.data) {
+		return nil, p.errorf("unexpected end of input")
+	}
+
+	switch c := p.data[p.offset]; {
+	case c == '{':
+		value, err = p.parseObject(node)
+	case c == '[':
+		value, err = p.parseArray(node)
+	case c == '"':
+		value, err = p.parseString()
+	case c == '-' || (c >= '0' && c <= '9'):
+		value, err = p.parseNumber()
+	case c == 't':
+		value, err = p.parseLiteral("true", true)
+	case c == 'f':
+		value, err = p.parseLiteral("false", false)
+	case c == 'n':
+		value, err = p.parseLiteral("null", nil)
+	default:
+		err = p.errorf("unexpected %q", c)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	node.end = p.lines.Position(p.offset)
+	return value, nil
+}
+
+func (p *jsonParser) parseObject(node *sourceNode) (interface{}, error) {
+	object := map[string]interface{}{}
+
+	p.offset++ // skip '{'
+	p.skipWhitespace()
+
+	if p.peek() == '}' {
+		p.offset++
+		return object, nil
+	}
+
+	for {
+		if p.peek() != '"' {
+			return nil, p.errorf("expected object key")
+		}
+
+		keyStart := p.lines.Position(p.offset)
+		key, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+
+		p.skipWhitespace()
+		if p.peek() != ':' {
+			return nil, p.errorf("expected ':' after object key")
+		}
+
+		p.offset++
+		p.skipWhitespace()
+
+		member := &sourceNode{sourceSpan: sourceSpan{key: keyStart}}
+
+		p.depth++
+		value, err := p.parseValue(member)
+		if err != nil {
+			return nil, err
+		}
+
+		p.depth--
+
+		// a duplicate key replaces the earlier value, including the positions
+		// of it and everything within it
+		object[key] = value
+		if node.members == nil {
+			node.members = map[string]*sourceNode{}
+		}
+
+		node.members[key] = member
+
+		p.skipWhitespace()
+		switch p.peek() {
+		case ',':
+			p.offset++
+			p.skipWhitespace()
+		case '}':
+			p.offset++
+			return object, nil
+		default:
+			return nil, p.errorf("expected ',' or '}' in object")
+		}
+	}
+}
+
+func (p *jsonParser) parseArray(node *sourceNode) (interface{}, error) {
+	array := []interface{}{}
+
+	p.offset++ // skip '['
+	p.skipWhitespace()
+
+	if p.peek() == ']' {
+		p.offset++
+		return array, nil
+	}
+
+	for {
+		elem := &sourceNode{}
+
+		p.depth++
+		value, err := p.parseValue(elem)
+		if err != nil {
+			return nil, err
+		}
+
+		p.depth--
+		array = append(array, value)
+		node.elems = append(node.elems, elem)
+
+		p.skipWhitespace()
+		switch p.peek() {
+		case ',':
+			p.offset++
+			p.skipWhitespace()
+		case ']':
+			p.offset++
+			return array, nil
+		default:
+			return nil, p.errorf("expected ',' or ']' in array")
+		}
+	}
+}
+
+func (p *jsonParser) parseString() (string, error) {
+	start := p.offset
+	p.offset++ // skip opening quote
+
+	escaped := false
+	for {
+		if p.offset >= len(p.data) {
+			return "", p.errorf("unterminated string")
+		}
+
+		switch c := p.data[p.offset]; {
+		case c == '"':
+			p.offset++
+
+			// most strings can be used as they are; encoding/json takes care of
+			// unescaping the rest, and of validating escapes and UTF-8
+			if !escaped && utf8.Valid(p.data[start+1:p.offset-1]) {
+				return string(p.data[start+1 : p.offset-1]), nil
+			}
+
+			var s string
+			if err := json.Unmarshal(p.data[start:p.offset], &s); err != nil {
+				return "", ErrSyntax{Message: "invalid string", Position: p.lines.Position(start)}
+			}
+
+			return s, nil
+		case c == '\\':
+			escaped = true
+			p.offset += 2
+		case c < 0x20:
+			return "", p.errorf("invalid character in string")
+		default:
+			p.offset++
+		}
+	}
+}
+
+func (p *jsonParser) parseNumber() (interface{}, error) {
+	start := p.offset
+
+	if p.peek() == '-' {
+		p.offset++
+	}
+
+	switch {
+	case p.peek() == '0':
+		p.offset++
+	case isDigit(p.peek()):
+		p.skipDigits()
+	default:
+		return nil, p.errorf("invalid number")
+	}
+
+	if p.peek() == '.' {
+		p.offset++
+		if !isDigit(p.peek()) {
+			return nil, p.errorf("invalid number")
+		}
+
+		p.skipDigits()
+	}
+
+	if p.peek() == 'e' || p.peek() == 'E' {
+		p.offset++
+		if p.peek() == '+' || p.peek() == '-' {
+			p.offset++
+		}
+
+		if !isDigit(p.peek()) {
+			return nil, p.errorf("invalid number")
+		}
+
+		p.skipDigits()
+	}
+
+	f, err := strconv.ParseFloat(string(p.data[start:p.offset]), 64)
+	if err != nil {
+		return nil, ErrSyntax{
+			Message:  fmt.Sprintf("number %s out of range", p.data[start:p.offset]),
+			Position: p.lines.Position(start),
+		}
+	}
+
+	return f, nil
+}
+
+func (p *jsonParser) parseLiteral(literal string, value interface{}) (interface{}, error) {
+	if len(p.data)-p.offset < len(literal) || string(p.data[p.offset:p.offset+len(literal)]) != literal {
+		return nil, p.errorf("invalid literal")
+	}
+
+	p.offset += len(literal)
+	return value, nil
+}
+
+// peek returns the byte at the current offset, or zero at the end of input.
+func (p *jsonParser) peek() byte {
+	if p.offset >= len(p.data) {
+		return 0
+	}
+
+	return p.data[p.offset]
+}
+
+func (p *jsonParser) skipDigits() {
+	for isDigit(p.peek()) {
+		p.offset++
+	}
+}
+
+func (p *jsonParser) skipWhitespace() {
+	for {
+		switch p.peek() {
+		case ' ', '\t', '\n', '\r':
+			p.offset++
+		default:
+			return
+		}
+	}
+}
+
+func (p *jsonParser) errorf(format string, args ...interface{}) error {
+	return ErrSyntax{
+		Message:  fmt.Sprintf(format, args...),
+		Position: p.lines.Position(p.offset),
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
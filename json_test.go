@@ -0,0 +1,262 @@
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"runtime"
+	"strings"
+	"testing"
+
+	jsonpointer "github.com/json-schema-spec/json-pointer-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseJSON(t *testing.T) {
+	testCases := []struct {
+		name string
+		json string
+		err  bool
+	}{
+		{"null", `null`, false},
+		{"booleans", `[true, false]`, false},
+		{"numbers", `[0, -0, 1.5, -12e3, 1E+2, 3.25e-1]`, false},
+		{"strings", `["", "a\"b", "é😀", "\/\b\f\n\r\t", "日本"]`, false},
+		{"nested", ` { "a": [ {}, [], {"b": null} ], "c": {"d": "e"} } `, false},
+		{"duplicate keys", `{"a": 1, "a": 2}`, false},
+		{"empty", ``, true},
+		{"trailing data", `{} {}`, true},
+		{"trailing comma", `[1,]`, true},
+		{"leading zero", `01`, true},
+		{"bare fraction", `1.`, true},
+		{"bad exponent", `1e`, true},
+		{"unterminated string", `"abc`, true},
+		{"bad escape", `"\x"`, true},
+		{"control character", "\"\t\"", true},
+		{"unquoted key", `{a: 1}`, true},
+		{"missing colon", `{"a" 1}`, true},
+		{"bad literal", `nul`, true},
+		{"out of range", `1e999`, true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseJSON([]byte(tt.json))
+
+			var expected interface{}
+			expectedErr := json.Unmarshal([]byte(tt.json), &expected)
+			assert.Equal(t, tt.err, expectedErr != nil)
+
+			if tt.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, expected, doc.Value)
+			}
+		})
+	}
+}
+
+func TestParseJSONSyntaxError(t *testing.T) {
+	_, err := ParseJSON([]byte("{\n  \"a\": tru\n}"))
+	assert.Equal(t, ErrSyntax{
+		Message:  "invalid literal",
+		Position: Position{Offset: 9, Line: 2, Column: 8},
+	}, err)
+}
+
+func TestParseJSONMaxDepth(t *testing.T) {
+	data := make([]byte, maxJSONDepth+2)
+	for i := range data {
+		data[i] = '['
+	}
+
+	_, err := ParseJSON(data)
+	assert.Error(t, err)
+}
+
+func TestDocumentSpan(t *testing.T) {
+	doc, err := ParseJSON([]byte("{\n  \"é\": [1, \"two\"],\n  \"b\": {\"c\": null}\n}"))
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		ptr   string
+		start Position
+		end   Position
+		ok    bool
+	}{
+		{"", Position{Offset: 0, Line: 1, Column: 1}, Position{Offset: 42, Line: 4, Column: 2}, true},
+		{"/é", Position{Offset: 10, Line: 2, Column: 8}, Position{Offset: 20, Line: 2, Column: 18}, true},
+		{"/é/0", Position{Offset: 11, Line: 2, Column: 9}, Position{Offset: 12, Line: 2, Column: 10}, true},
+		{"/é/1", Position{Offset: 14, Line: 2, Column: 12}, Position{Offset: 19, Line: 2, Column: 17}, true},
+		{"/b/c", Position{Offset: 35, Line: 3, Column: 14}, Position{Offset: 39, Line: 3, Column: 18}, true},
+		{"/nope", Position{}, Position{}, false},
+		{"/é/01", Position{}, Position{}, false},
+		{"/é/2", Position{}, Position{}, false},
+		{"/b/c/d", Position{}, Position{}, false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.ptr, func(t *testing.T) {
+			ptr, err := jsonpointer.New(tt.ptr)
+			assert.NoError(t, err)
+
+			start, end, ok := doc.Span(ptr)
+			assert.Equal(t, tt.start, start)
+			assert.Equal(t, tt.end, end)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
+
+func TestDocumentSpanDuplicateKeys(t *testing.T) {
+	doc, err := ParseJSON([]byte(`{"a": {"b": [1]}, "a": 2}`))
+	assert.NoError(t, err)
+
+	start, _, ok := doc.Span(jsonpointer.Ptr{Tokens: []string{"a"}})
+	assert.True(t, ok)
+	assert.Equal(t, Position{Offset: 23, Line: 1, Column: 24}, start)
+
+	// nothing remains of the value the duplicate replaced
+	_, _, ok = doc.Span(jsonpointer.Ptr{Tokens: []string{"a", "b"}})
+	assert.False(t, ok)
+
+	_, _, ok = doc.Span(jsonpointer.Ptr{Tokens: []string{"a", "b", "0"}})
+	assert.False(t, ok)
+}
+
+func TestParseJSONMemory(t *testing.T) {
+	// a long key above many values, and deep nesting, are cheap to parse but
+	// would be expensive if each value's source map entry held the whole
+	// pointer to it
+	var wide bytes.Buffer
+	wide.WriteString(`{"` + strings.Repeat("k", 10000) + `": [`)
+	for i := 0; i < 10000; i++ {
+		if i > 0 {
+			wide.WriteString(",")
+		}
+
+		wide.WriteString("0")
+	}
+	wide.WriteString("]}")
+
+	deep := strings.Repeat("[", 9000) + strings.Repeat("]", 9000)
+
+	for _, data := range [][]byte{wide.Bytes(), []byte(deep)} {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+
+		_, err := ParseJSON(data)
+		assert.NoError(t, err)
+
+		runtime.ReadMemStats(&after)
+		assert.True(t, after.TotalAlloc-before.TotalAlloc < uint64(200*len(data)), "allocated %d bytes parsing %d bytes", after.TotalAlloc-before.TotalAlloc, len(data))
+	}
+}
+
+func TestValidatorValidateDocument(t *testing.T) {
+	schema, err := ParseJSON([]byte(`{
+  "properties": {
+    "name": { "type": "string" },
+    "tags": { "items": { "$ref": "http://example.com/tag" } }
+  }
+}`))
+	assert.NoError(t, err)
+
+	tag, err := ParseJSON([]byte(`{ "$id": "http://example.com/tag", "maxLength": 3 }`))
+	assert.NoError(t, err)
+
+	validator, err := NewValidatorFromDocuments([]Document{schema, tag})
+	assert.NoError(t, err)
+
+	instance, err := ParseJSON([]byte(`{"name": 3, "tags": ["ok", "toolong"]}`))
+	assert.NoError(t, err)
+
+	result, err := validator.ValidateDocument(instance)
+	assert.NoError(t, err)
+	assert.Equal(t, []ValidationError{
+		ValidationError{
+			InstancePath:  jsonpointer.Ptr{Tokens: []string{"name"}},
+			SchemaPath:    jsonpointer.Ptr{Tokens: []string{"properties", "name", "type"}},
+			InstanceStart: Position{Offset: 9, Line: 1, Column: 10},
+			InstanceEnd:   Position{Offset: 10, Line: 1, Column: 11},
+			SchemaStart:   Position{Offset: 34, Line: 3, Column: 15},
+		},
+		ValidationError{
+			InstancePath:  jsonpointer.Ptr{Tokens: []string{"tags", "1"}},
+			SchemaPath:    jsonpointer.Ptr{Tokens: []string{"maxLength"}},
+			URI:           url.URL{Scheme: "http", Host: "example.com", Path: "/tag"},
+			InstanceStart: Position{Offset: 27, Line: 1, Column: 28},
+			InstanceEnd:   Position{Offset: 36, Line: 1, Column: 37},
+			SchemaStart:   Position{Offset: 35, Line: 1, Column: 36},
+		},
+	}, result.Errors)
+
+	// without a Document, only schema positions are known
+	result, err = validator.Validate(instance.Value)
+	assert.NoError(t, err)
+	assert.False(t, result.Errors[0].InstanceStart.IsValid())
+	assert.True(t, result.Errors[0].SchemaStart.IsValid())
+}
+
+func BenchmarkParseJSON(b *testing.B) {
+	// a large document on a single line, as minified JSON often is
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i := 0; i < 5000; i++ {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+
+		fmt.Fprintf(&buf, `{"id":%d,"name":"café %d","tags":["a/b","c~d"],"active":true}`, i, i)
+	}
+	buf.WriteString("]")
+
+	data := buf.Bytes()
+
+	b.Run("ParseJSON", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			if _, err := ParseJSON(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("encoding/json", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			var value interface{}
+			if err := json.Unmarshal(data, &value); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestLineIndex(t *testing.T) {
+	data := []byte("[\"é\", \"ü\",\n  \"ñ\"]")
+
+	// positions asked for in any order are the same as ones asked for afresh
+	offsets := []int{0, 1, 7, 2, 18, 15, 12, 13, 10}
+	lines := newLineIndex(data)
+	for _, offset := range offsets {
+		fresh := newLineIndex(data)
+		assert.Equal(t, fresh.Position(offset), lines.Position(offset))
+	}
+
+	assert.Equal(t, Position{Offset: 7, Line: 1, Column: 7}, lines.Position(7))
+	assert.Equal(t, Position{Offset: 15, Line: 2, Column: 3}, lines.Position(15))
+
+	for _, offset := range offsets {
+		want := lines.Position(offset)
+		fresh := newLineIndex(data)
+		assert.Equal(t, fresh.LineColumn(want.Line, want.Column), lines.LineColumn(want.Line, want.Column))
+		assert.Equal(t, want, lines.LineColumn(want.Line, want.Column))
+	}
+}
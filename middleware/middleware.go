@@ -0,0 +1,264 @@
+// Package middleware validates the JSON bodies of HTTP requests and responses
+// against JSON Schemas.
+//
+// Requests whose bodies are rejected are answered with an RFC 7807 problem
+// details response, so handlers only ever see bodies which are valid JSON and
+// match their schema:
+//
+//	m := middleware.New(&validator, middleware.Config{})
+//	http.Handle("/users", m.Handler(middleware.Route{Request: &userURI}, usersHandler))
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	jsonschema "github.com/json-schema-spec/json-schema-go"
+	"github.com/json-schema-spec/json-schema-go/internal/jsonbody"
+)
+
+// DefaultMaxBodyBytes is the default value of MaxBodyBytes in Config.
+const DefaultMaxBodyBytes = 1 << 20
+
+// Config contains configuration for a Middleware.
+type Config struct {
+	// MaxBodyBytes is the largest request body the middleware will accept.
+	// Larger bodies are rejected with status 413.
+	//
+	// A value of zero indicates to use DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+
+	// ValidateResponses indicates that response bodies should also be
+	// validated, for routes with a Response schema. Responses which fail
+	// validation are replaced with a problem with status 500.
+	//
+	// Validating responses requires buffering them in full, and so is
+	// intended for use while debugging or testing, not in production.
+	ValidateResponses bool
+
+	// ProblemType is the type of the problems written when a body fails
+	// validation.
+	//
+	// A value of "" indicates to use DefaultProblemType.
+	ProblemType string
+}
+
+// Route identifies the schemas which the bodies of a route must match.
+type Route struct {
+	// Request is the URI of the schema request bodies must match. If nil,
+	// request bodies are not validated.
+	Request *url.URL
+
+	// Response is the URI of the schema successful responses must match, if
+	// Config.ValidateResponses is set. If nil, responses are not validated.
+	//
+	// Only responses with a 2xx status and a JSON content type are validated.
+	Response *url.URL
+}
+
+// Middleware validates bodies of requests and responses using a Validator.
+//
+// A Middleware is safe for concurrent use by multiple goroutines.
+type Middleware struct {
+	validator *jsonschema.Validator
+	config    Config
+}
+
+// New constructs a Middleware which validates bodies using the given
+// validator and config.
+func New(validator *jsonschema.Validator, config Config) *Middleware {
+	if config.MaxBodyBytes == 0 {
+		config.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+
+	if config.ProblemType == "" {
+		config.ProblemType = DefaultProblemType
+	}
+
+	return &Middleware{validator: validator, config: config}
+}
+
+// Handler returns a handler which validates the bodies of route before and
+// after passing requests to next.
+func (m *Middleware) Handler(route Route, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route.Request != nil {
+			if problem, ok := m.validateRequest(*route.Request, w, r); !ok {
+				WriteProblem(w, problem)
+				return
+			}
+		}
+
+		if route.Response == nil || !m.config.ValidateResponses {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		recorder := newResponseRecorder()
+		next.ServeHTTP(recorder, r)
+
+		if problem, ok := m.validateResponse(*route.Response, r, recorder); !ok {
+			WriteProblem(w, problem)
+			return
+		}
+
+		recorder.WriteTo(w)
+	})
+}
+
+// Wrap returns a function which applies Handler for route, for use with
+// routers which compose middleware as func(http.Handler) http.Handler.
+func (m *Middleware) Wrap(route Route) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return m.Handler(route, next)
+	}
+}
+
+// validateRequest reads and validates the body of r. If the body is accepted,
+// it is put back in r for the next handler to read.
+func (m *Middleware) validateRequest(uri url.URL, w http.ResponseWriter, r *http.Request) (Problem, bool) {
+	if !jsonbody.IsJSON(r.Header.Get("Content-Type")) {
+		return newProblem(http.StatusUnsupportedMediaType, "request body must be JSON"), false
+	}
+
+	// the body is read through MaxBytesReader even if ContentLength is set,
+	// as ContentLength can be wrong
+	if r.ContentLength > m.config.MaxBodyBytes {
+		return newProblem(http.StatusRequestEntityTooLarge, m.tooLargeDetail()), false
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, m.config.MaxBodyBytes))
+	if err != nil {
+		if int64(len(body)) >= m.config.MaxBodyBytes {
+			return newProblem(http.StatusRequestEntityTooLarge, m.tooLargeDetail()), false
+		}
+
+		return newProblem(http.StatusBadRequest, fmt.Sprintf("reading request body: %v", err)), false
+	}
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	instance, err := jsonbody.Decode(body)
+	if err != nil {
+		return newProblem(http.StatusBadRequest, fmt.Sprintf("request body is malformed: %v", err)), false
+	}
+
+	result, err := m.validator.ValidateURIContext(r.Context(), uri, instance)
+	if err != nil {
+		return m.evaluationProblem(err), false
+	}
+
+	if !result.IsValid() {
+		return newValidationProblem(m.config.ProblemType, http.StatusBadRequest, "request body failed validation", result), false
+	}
+
+	return Problem{}, true
+}
+
+// validateResponse validates the response recorded from a handler.
+func (m *Middleware) validateResponse(uri url.URL, r *http.Request, recorder *responseRecorder) (Problem, bool) {
+	if recorder.status < 200 || recorder.status > 299 || !jsonbody.IsJSON(recorder.header.Get("Content-Type")) {
+		return Problem{}, true
+	}
+
+	instance, err := jsonbody.Decode(recorder.body.Bytes())
+	if err != nil {
+		return newProblem(http.StatusInternalServerError, fmt.Sprintf("response body is malformed: %v", err)), false
+	}
+
+	result, err := m.validator.ValidateURIContext(r.Context(), uri, instance)
+	if err != nil {
+		if problem, ok := contextProblem(err); ok {
+			return problem, false
+		}
+
+		return newProblem(http.StatusInternalServerError, fmt.Sprintf("validating response body: %v", err)), false
+	}
+
+	if !result.IsValid() {
+		return newValidationProblem(m.config.ProblemType, http.StatusInternalServerError, "response body failed validation", result), false
+	}
+
+	return Problem{}, true
+}
+
+// evaluationProblem describes an error from evaluating a request body.
+func (m *Middleware) evaluationProblem(err error) Problem {
+	if problem, ok := contextProblem(err); ok {
+		return problem
+	}
+
+	if _, ok := err.(jsonschema.ErrBudgetExceeded); ok {
+		// the request body was too large or complex to evaluate, which is the
+		// client's problem
+		return newProblem(http.StatusBadRequest, fmt.Sprintf("request body is too complex: %v", err))
+	}
+
+	return newProblem(http.StatusInternalServerError, fmt.Sprintf("validating request body: %v", err))
+}
+
+// contextProblem describes an error from the context of a request, which
+// stopped a body from being evaluated. The validator returns ctx.Err() as it
+// is, so the errors are compared directly.
+func contextProblem(err error) (Problem, bool) {
+	switch err {
+	case context.DeadlineExceeded:
+		// the server ran out of time, which is not the client's fault, and may
+		// not happen if the request is retried
+		return newProblem(http.StatusServiceUnavailable, "validating body: deadline exceeded"), true
+	case context.Canceled:
+		// the client has most likely gone away, and will not see the response
+		return newProblem(http.StatusServiceUnavailable, "validating body: request cancelled"), true
+	default:
+		return Problem{}, false
+	}
+}
+
+func (m *Middleware) tooLargeDetail() string {
+	return fmt.Sprintf("request body must be at most %d bytes", m.config.MaxBodyBytes)
+}
+
+// responseRecorder buffers a response so that it can be validated before it is
+// sent.
+type responseRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{header: http.Header{}}
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	return r.body.Write(data)
+}
+
+// WriteTo sends the recorded response to w.
+func (r *responseRecorder) WriteTo(w http.ResponseWriter) {
+	for key, values := range r.header {
+		w.Header()[key] = values
+	}
+
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	w.WriteHeader(r.status)
+	w.Write(r.body.Bytes())
+}
@@ -0,0 +1,293 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	jsonschema "github.com/json-schema-spec/json-schema-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	validator, err := jsonschema.NewValidator([]interface{}{
+		map[string]interface{}{
+			"$id":      "http://example.com/user",
+			"required": []interface{}{"name"},
+			"properties": map[string]interface{}{
+				"name": map[string]interface{}{"type": "string"},
+				"age":  map[string]interface{}{"type": "integer"},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	userURI := url.URL{Scheme: "http", Host: "example.com", Path: "/user"}
+
+	testCases := []struct {
+		name        string
+		config      Config
+		contentType string
+		body        string
+		response    string
+		status      int
+		problem     *Problem
+	}{
+		{
+			"valid",
+			Config{},
+			"application/json",
+			`{"name": "alice"}`,
+			`{"name": "alice"}`,
+			http.StatusOK,
+			nil,
+		},
+		{
+			"invalid",
+			Config{},
+			"application/json; charset=utf-8",
+			`{"age": "old"}`,
+			"",
+			http.StatusBadRequest,
+			&Problem{
+				Type:   DefaultProblemType,
+				Title:  "Bad Request",
+				Status: http.StatusBadRequest,
+				Detail: "request body failed validation",
+				Errors: []ProblemError{
+					ProblemError{InstancePath: "", SchemaPath: "/required/0", URI: "http://example.com/user"},
+					ProblemError{InstancePath: "/age", SchemaPath: "/properties/age/type", URI: "http://example.com/user"},
+				},
+				ErrorCount: 2,
+			},
+		},
+		{
+			"custom problem type",
+			Config{ProblemType: "https://example.com/problems/invalid"},
+			"",
+			`{}`,
+			"",
+			http.StatusBadRequest,
+			&Problem{
+				Type:   "https://example.com/problems/invalid",
+				Title:  "Bad Request",
+				Status: http.StatusBadRequest,
+				Detail: "request body failed validation",
+				Errors: []ProblemError{
+					ProblemError{InstancePath: "", SchemaPath: "/required/0", URI: "http://example.com/user"},
+				},
+				ErrorCount: 1,
+			},
+		},
+		{
+			"malformed",
+			Config{},
+			"application/json",
+			`{"name": `,
+			"",
+			http.StatusBadRequest,
+			nil,
+		},
+		{
+			"trailing data",
+			Config{},
+			"application/json",
+			`{"name": "alice"} {}`,
+			"",
+			http.StatusBadRequest,
+			nil,
+		},
+		{
+			"not json",
+			Config{},
+			"text/plain",
+			`{"name": "alice"}`,
+			"",
+			http.StatusUnsupportedMediaType,
+			nil,
+		},
+		{
+			"too large",
+			Config{MaxBodyBytes: 8},
+			"application/json",
+			`{"name": "alice"}`,
+			"",
+			http.StatusRequestEntityTooLarge,
+			&Problem{
+				Type:   DefaultProblemType,
+				Title:  "Request Entity Too Large",
+				Status: http.StatusRequestEntityTooLarge,
+				Detail: "request body must be at most 8 bytes",
+			},
+		},
+		{
+			"invalid response ignored",
+			Config{},
+			"application/json",
+			`{"name": "alice"}`,
+			`{"name": 3}`,
+			http.StatusOK,
+			nil,
+		},
+		{
+			"invalid response",
+			Config{ValidateResponses: true},
+			"application/json",
+			`{"name": "alice"}`,
+			`{"name": 3}`,
+			http.StatusInternalServerError,
+			&Problem{
+				Type:   DefaultProblemType,
+				Title:  "Internal Server Error",
+				Status: http.StatusInternalServerError,
+				Detail: "response body failed validation",
+				Errors: []ProblemError{
+					ProblemError{InstancePath: "/name", SchemaPath: "/properties/name/type", URI: "http://example.com/user"},
+				},
+				ErrorCount: 1,
+			},
+		},
+		{
+			"valid response",
+			Config{ValidateResponses: true},
+			"application/json",
+			`{"name": "alice"}`,
+			`{"name": "bob"}`,
+			http.StatusOK,
+			nil,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// the handler can still read the body
+				body, err := ioutil.ReadAll(r.Body)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.body, string(body))
+
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(tt.response))
+			})
+
+			m := New(&validator, tt.config)
+			handler := m.Handler(Route{Request: &userURI, Response: &userURI}, next)
+
+			req := httptest.NewRequest("POST", "/users", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.status == http.StatusOK {
+				assert.Equal(t, tt.response, rec.Body.String())
+				return
+			}
+
+			assert.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
+
+			var problem Problem
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+			assert.Equal(t, tt.status, problem.Status)
+
+			if tt.problem != nil {
+				assert.Equal(t, *tt.problem, problem)
+			}
+		})
+	}
+}
+
+func TestMiddlewareWrap(t *testing.T) {
+	validator, err := jsonschema.NewValidator([]interface{}{
+		map[string]interface{}{"type": "array"},
+	})
+	assert.NoError(t, err)
+
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	handler := New(&validator, Config{}).Wrap(Route{Request: &url.URL{}})(next)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.False(t, called)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`[]`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, called)
+}
+
+func TestMiddlewareBudgetExceeded(t *testing.T) {
+	validator, err := jsonschema.NewValidatorWithConfig([]interface{}{
+		map[string]interface{}{"items": map[string]interface{}{"$ref": "#"}},
+	}, jsonschema.ValidatorConfig{MaxStackDepth: jsonschema.DefaultMaxStackDepth, MaxInstanceDepth: 2})
+	assert.NoError(t, err)
+
+	handler := New(&validator, Config{}).Handler(Route{Request: &url.URL{}}, http.NotFoundHandler())
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`[[[[]]]]`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestMiddlewareContextDone(t *testing.T) {
+	validator, err := jsonschema.NewValidator([]interface{}{
+		map[string]interface{}{"type": "object"},
+	})
+	assert.NoError(t, err)
+
+	m := New(&validator, Config{ValidateResponses: true})
+	handler := m.Handler(Route{Request: &url.URL{}, Response: &url.URL{}}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	testCases := []struct {
+		name   string
+		ctx    context.Context
+		detail string
+	}{
+		{"cancelled", cancelled, "validating body: request cancelled"},
+		{"deadline exceeded", expired, "validating body: deadline exceeded"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("POST", "/", strings.NewReader(`{}`)).WithContext(tt.ctx))
+			assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+			var problem Problem
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+			assert.Equal(t, tt.detail, problem.Detail)
+
+			// responses are validated with the context of the request too
+			recorder := newResponseRecorder()
+			recorder.Header().Set("Content-Type", "application/json")
+			recorder.Write([]byte(`{}`))
+
+			problem, ok := m.validateResponse(url.URL{}, httptest.NewRequest("GET", "/", nil).WithContext(tt.ctx), recorder)
+			assert.False(t, ok)
+			assert.Equal(t, http.StatusServiceUnavailable, problem.Status)
+		})
+	}
+}
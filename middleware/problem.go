@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+
+	jsonschema "github.com/json-schema-spec/json-schema-go"
+)
+
+// ProblemContentType is the media type of problem details, as defined by RFC
+// 7807.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object, which the middleware writes
+// in response to requests it rejects.
+type Problem struct {
+	// Type is a URI identifying the kind of problem.
+	Type string `json:"type"`
+
+	// Title is a short, human-readable summary of the kind of problem.
+	Title string `json:"title"`
+
+	// Status is the HTTP status code of the response.
+	Status int `json:"status"`
+
+	// Detail is a human-readable explanation of this occurrence of the
+	// problem.
+	Detail string `json:"detail,omitempty"`
+
+	// Errors holds the errors from validating a body, if validation failed.
+	Errors []ProblemError `json:"errors,omitempty"`
+
+	// ErrorCount is the total number of errors from validating a body, which
+	// may exceed the number of Errors if the Validator limits how many errors
+	// it returns.
+	ErrorCount int `json:"errorCount,omitempty"`
+}
+
+// ProblemError is a ValidationError, as it is presented in a Problem.
+type ProblemError struct {
+	InstancePath string `json:"instancePath"`
+	SchemaPath   string `json:"schemaPath"`
+	URI          string `json:"uri"`
+	Duplicates   []int  `json:"duplicates,omitempty"`
+}
+
+// DefaultProblemType is the default value of ProblemType in Config. It is the
+// type RFC 7807 reserves for problems with no semantics beyond their status
+// code.
+const DefaultProblemType = "about:blank"
+
+func newProblem(status int, detail string) Problem {
+	return Problem{
+		Type:   DefaultProblemType,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+func newValidationProblem(problemType string, status int, detail string, result jsonschema.ValidationResult) Problem {
+	errors := make([]ProblemError, len(result.Errors))
+	for i, e := range result.Errors {
+		errors[i] = ProblemError{
+			InstancePath: e.InstancePath.String(),
+			SchemaPath:   e.SchemaPath.String(),
+			URI:          e.URI.String(),
+			Duplicates:   e.Duplicates,
+		}
+	}
+
+	return Problem{
+		Type:       problemType,
+		Title:      http.StatusText(status),
+		Status:     status,
+		Detail:     detail,
+		Errors:     errors,
+		ErrorCount: result.ErrorCount,
+	}
+}
+
+// WriteProblem writes p as the response to a request.
+func WriteProblem(w http.ResponseWriter, p Problem) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		// a Problem only holds strings and numbers, which can always be
+		// encoded
+		panic(err)
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	w.Write(body)
+}
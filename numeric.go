@@ -0,0 +1,43 @@
+package jsonschema
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// isMultipleOf determines whether val is a multiple of the value of a
+// "multipleOf" keyword.
+func (vm *vm) isMultipleOf(val float64, multipleOf schemaMultipleOf) bool {
+	if math.IsInf(val, 0) || math.IsNaN(val) {
+		// no multiple of a finite number is infinite
+		return false
+	}
+
+	if vm.exactDecimal {
+		quotient := decimalFromFloat(val)
+		quotient.Quo(quotient, multipleOf.Decimal)
+		return quotient.IsInt()
+	}
+
+	// the tolerance is relative to small divisors, so that they are not swamped
+	// by it, but never exceeds epsilon, so that large divisors do not accept
+	// values well short of a multiple; the remainder may fall just short of the
+	// divisor rather than just above zero, as it does for 0.3 and 0.1
+	tolerance := math.Min(vm.epsilon, vm.epsilon*multipleOf.Value)
+	remainder := math.Abs(math.Mod(val, multipleOf.Value))
+	return remainder <= tolerance || multipleOf.Value-remainder <= tolerance
+}
+
+// isInteger determines whether f is within epsilon of an integer.
+func isInteger(f, epsilon float64) bool {
+	return math.Abs(f-math.Round(f)) <= epsilon
+}
+
+// decimalFromFloat returns the exact value of the shortest decimal
+// representation of f. This is the number as it would have been written in a
+// JSON document, rather than its binary floating-point approximation.
+func decimalFromFloat(f float64) *big.Rat {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(f, 'g', -1, 64))
+	return r
+}
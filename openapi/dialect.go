@@ -0,0 +1,71 @@
+package openapi
+
+// normalizeSchema rewrites, in place, the parts of an OpenAPI 3.0 schema which
+// differ from JSON Schema into their JSON Schema equivalents:
+//
+// "nullable": true allows null in addition to the declared "type" and "enum".
+//
+// "exclusiveMaximum" and "exclusiveMinimum" are booleans modifying "maximum"
+// and "minimum", rather than numbers of their own.
+//
+// Rewriting is idempotent, so schemas reached more than once are unaffected
+// by being rewritten again.
+func normalizeSchema(schema interface{}) {
+	object, ok := schema.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	if nullable, ok := object["nullable"].(bool); ok {
+		delete(object, "nullable")
+
+		if nullable {
+			if schemaType, ok := object["type"].(string); ok {
+				object["type"] = []interface{}{schemaType, "null"}
+			}
+
+			if enum, ok := object["enum"].([]interface{}); ok {
+				object["enum"] = append(enum, nil)
+			}
+		}
+	}
+
+	normalizeBound(object, "exclusiveMaximum", "maximum")
+	normalizeBound(object, "exclusiveMinimum", "minimum")
+
+	for _, keyword := range []string{"not", "items", "additionalItems", "additionalProperties"} {
+		if value, ok := object[keyword]; ok {
+			normalizeSchema(value)
+		}
+	}
+
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf", "items"} {
+		if elems, ok := object[keyword].([]interface{}); ok {
+			for _, elem := range elems {
+				normalizeSchema(elem)
+			}
+		}
+	}
+
+	for _, keyword := range []string{"properties", "patternProperties"} {
+		if members, ok := object[keyword].(map[string]interface{}); ok {
+			for _, member := range members {
+				normalizeSchema(member)
+			}
+		}
+	}
+}
+
+// normalizeBound replaces a boolean exclusive bound with a numeric one.
+func normalizeBound(object map[string]interface{}, exclusive, inclusive string) {
+	value, ok := object[exclusive].(bool)
+	if !ok {
+		return
+	}
+
+	delete(object, exclusive)
+	if bound, ok := object[inclusive]; ok && value {
+		object[exclusive] = bound
+		delete(object, inclusive)
+	}
+}
@@ -0,0 +1,583 @@
+// Package openapi validates HTTP requests and responses against the operations
+// described in an OpenAPI 3 document.
+//
+// Every schema in the document which describes a parameter, request body or
+// response body is compiled into a single jsonschema.Validator. Each schema is
+// known by a URI whose fragment is a JSON Pointer to it within the document,
+// such as "#/paths/~1users/post/requestBody/content/application~1json/schema",
+// and these URIs appear in the errors validation produces.
+//
+// Only schemas of JSON media types, such as "application/json", are used for
+// bodies. References ("$ref") must be to parts of the same document.
+//
+// Parameters may have the "simple", "form", "spaceDelimited" and
+// "pipeDelimited" styles. Documents using other styles, such as "matrix" or
+// "deepObject", are rejected. Header parameters named "Accept",
+// "Authorization" or "Content-Type" are ignored, as the specification requires.
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	jsonpointer "github.com/json-schema-spec/json-pointer-go"
+	jsonschema "github.com/json-schema-spec/json-schema-go"
+	"github.com/json-schema-spec/json-schema-go/internal/jsonbody"
+)
+
+// ErrNoOperation indicates that no operation in the document matches the
+// method and path of a request.
+var ErrNoOperation = errors.New("no operation matches the request")
+
+// maxRefDepth is how many "$ref"-s in a row will be followed when resolving
+// parts of the document which are not schemas, such as parameters.
+const maxRefDepth = 32
+
+// methods are the HTTP methods which may have operations in a path item.
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Validator validates requests and responses against an OpenAPI document.
+//
+// A Validator is safe for concurrent use by multiple goroutines.
+type Validator struct {
+	validator  jsonschema.Validator
+	operations []*operation
+
+	// prefixes holds the paths of the document's servers, which are stripped
+	// from requests before they are matched to operations.
+	prefixes []string
+}
+
+// operation is a compiled OpenAPI operation.
+type operation struct {
+	method   string
+	path     string
+	segments []segment
+
+	parameters  []parameter
+	requestBody *requestBody
+
+	// responses holds the response bodies of the operation, keyed by status
+	// code, range (such as "2XX"), or "default".
+	responses map[string]*responseBody
+}
+
+// segment is part of a path template. Literal segments have no pattern.
+type segment struct {
+	literal string
+	pattern *regexp.Regexp
+	names   []string
+}
+
+// ignoredHeaders are the header parameters the specification requires be
+// ignored.
+var ignoredHeaders = map[string]bool{
+	"Accept":        true,
+	"Authorization": true,
+	"Content-Type":  true,
+}
+
+// defaultStyles are the styles of parameters which do not declare one, by
+// location.
+var defaultStyles = map[string]string{
+	"path":   "simple",
+	"query":  "form",
+	"header": "simple",
+	"cookie": "form",
+}
+
+// styleSeparators are the separators of the elements of arrays which are not
+// exploded, by location and then by style. Styles which are missing, such as
+// "matrix" or "deepObject", are not supported.
+var styleSeparators = map[string]map[string]string{
+	"path":   {"simple": ","},
+	"query":  {"form": ",", "spaceDelimited": " ", "pipeDelimited": "|"},
+	"header": {"simple": ","},
+	"cookie": {"form": ","},
+}
+
+type parameter struct {
+	name     string
+	in       string
+	required bool
+	explode  bool
+
+	// separator separates the elements of arrays which are not exploded.
+	separator string
+
+	// schema is the URI of the parameter's schema, if it has one.
+	schema *url.URL
+
+	// schemaType is the type declared by the schema, which decides whether
+	// the parameter is split into an array.
+	schemaType string
+}
+
+type requestBody struct {
+	required bool
+
+	// mediaTypes lists the media types the body may have.
+	mediaTypes []string
+
+	// schema is the URI of the schema of JSON bodies, if there is one.
+	schema *url.URL
+}
+
+type responseBody struct {
+	schema *url.URL
+}
+
+// New constructs a Validator from an OpenAPI document, which must be in the
+// data model produced by encoding/json. The document is not modified.
+func New(document interface{}) (*Validator, error) {
+	return NewWithConfig(document, jsonschema.ValidatorConfig{
+		MaxStackDepth: jsonschema.DefaultMaxStackDepth,
+	})
+}
+
+// NewWithConfig is like New, but configures the underlying jsonschema.Validator
+// with config. Any SubSchemaURIs in config are kept, alongside those of the
+// document's operations.
+func NewWithConfig(document interface{}, config jsonschema.ValidatorConfig) (*Validator, error) {
+	root, ok := deepCopy(document).(map[string]interface{})
+	if !ok {
+		return nil, errors.New("openapi: document is not an object")
+	}
+
+	version, _ := root["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("openapi: unsupported version: %q", version)
+	}
+
+	c := compiler{root: root, dialect30: strings.HasPrefix(version, "3.0")}
+	if c.dialect30 {
+		if components, ok := root["components"].(map[string]interface{}); ok {
+			if schemas, ok := components["schemas"].(map[string]interface{}); ok {
+				for _, schema := range schemas {
+					normalizeSchema(schema)
+				}
+			}
+		}
+	}
+
+	operations, err := c.compileOperations()
+	if err != nil {
+		return nil, err
+	}
+
+	config.SubSchemaURIs = append(config.SubSchemaURIs, c.uris...)
+	validator, err := jsonschema.NewValidatorWithConfig([]interface{}{root}, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Validator{
+		validator:  validator,
+		operations: operations,
+		prefixes:   serverPrefixes(root),
+	}, nil
+}
+
+// Load constructs a Validator from an OpenAPI document written in JSON or
+// YAML.
+func Load(data []byte) (*Validator, error) {
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		docs, yamlErr := jsonschema.DecodeYAML(data)
+		if yamlErr != nil {
+			return nil, yamlErr
+		}
+
+		if len(docs) != 1 {
+			return nil, errors.New("openapi: document must contain exactly one YAML document")
+		}
+
+		document = docs[0]
+	}
+
+	return New(document)
+}
+
+// compiler gathers the operations of a document, along with the URIs of the
+// schemas they use.
+type compiler struct {
+	root      map[string]interface{}
+	dialect30 bool
+	uris      []url.URL
+}
+
+func (c *compiler) compileOperations() ([]*operation, error) {
+	paths, _ := c.root["paths"].(map[string]interface{})
+	operations := []*operation{}
+
+	for _, path := range sortedKeys(paths) {
+		item, tokens, err := c.resolve(paths[path], []string{"paths", path})
+		if err != nil {
+			return nil, err
+		}
+
+		segments, err := compilePath(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, method := range methods {
+			value, ok := item[method]
+			if !ok {
+				continue
+			}
+
+			op, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("openapi: %s %s: operation is not an object", method, path)
+			}
+
+			opTokens := appendTokens(tokens, method)
+			compiled := &operation{
+				method:    strings.ToUpper(method),
+				path:      path,
+				segments:  segments,
+				responses: map[string]*responseBody{},
+			}
+
+			// parameters of the operation override those of the path item
+			// with the same name and location
+			compiled.parameters, err = c.compileParameters(item["parameters"], appendTokens(tokens, "parameters"), nil)
+			if err != nil {
+				return nil, err
+			}
+
+			compiled.parameters, err = c.compileParameters(op["parameters"], appendTokens(opTokens, "parameters"), compiled.parameters)
+			if err != nil {
+				return nil, err
+			}
+
+			if value, ok := op["requestBody"]; ok {
+				compiled.requestBody, err = c.compileRequestBody(value, appendTokens(opTokens, "requestBody"))
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			responses, _ := op["responses"].(map[string]interface{})
+			for _, status := range sortedKeys(responses) {
+				value, tokens, err := c.resolve(responses[status], appendTokens(opTokens, "responses", status))
+				if err != nil {
+					return nil, err
+				}
+
+				_, schema := c.compileContent(value["content"], appendTokens(tokens, "content"))
+				compiled.responses[strings.ToUpper(status)] = &responseBody{schema: schema}
+			}
+
+			operations = append(operations, compiled)
+		}
+	}
+
+	// concrete paths are matched before templated ones, as the specification
+	// requires
+	sort.SliceStable(operations, func(i, j int) bool {
+		return templateCount(operations[i].segments) < templateCount(operations[j].segments)
+	})
+
+	return operations, nil
+}
+
+func (c *compiler) compileParameters(value interface{}, tokens []string, inherited []parameter) ([]parameter, error) {
+	parameters := append([]parameter{}, inherited...)
+
+	elems, _ := value.([]interface{})
+	for i, elem := range elems {
+		param, paramTokens, err := c.resolve(elem, appendTokens(tokens, fmt.Sprint(i)))
+		if err != nil {
+			return nil, err
+		}
+
+		name, _ := param["name"].(string)
+		in, _ := param["in"].(string)
+		required, _ := param["required"].(bool)
+
+		// the specification requires these headers be ignored, as they are
+		// described elsewhere in the operation, or by its security schemes
+		if in == "header" && ignoredHeaders[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+
+		style, ok := param["style"].(string)
+		if !ok {
+			style = defaultStyles[in]
+		}
+
+		separator, ok := styleSeparators[in][style]
+		if !ok {
+			return nil, fmt.Errorf("openapi: %s: unsupported style %q for %s parameter", jsonpointer.Ptr{Tokens: paramTokens}.String(), style, in)
+		}
+
+		// form style, the default for query and cookie parameters, explodes
+		// arrays by default
+		explode := style == "form"
+		if value, ok := param["explode"].(bool); ok {
+			explode = value
+		}
+
+		compiled := parameter{
+			name:      name,
+			in:        in,
+			required:  required || in == "path",
+			explode:   explode,
+			separator: separator,
+		}
+
+		if schema, ok := param["schema"]; ok {
+			uri := c.addSchema(schema, appendTokens(paramTokens, "schema"))
+			compiled.schema = &uri
+			compiled.schemaType = c.schemaType(schema)
+		}
+
+		replaced := false
+		for j := range parameters {
+			if parameters[j].name == compiled.name && parameters[j].in == compiled.in {
+				parameters[j] = compiled
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			parameters = append(parameters, compiled)
+		}
+	}
+
+	return parameters, nil
+}
+
+func (c *compiler) compileRequestBody(value interface{}, tokens []string) (*requestBody, error) {
+	body, tokens, err := c.resolve(value, tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	required, _ := body["required"].(bool)
+	mediaTypes, schema := c.compileContent(body["content"], appendTokens(tokens, "content"))
+
+	return &requestBody{required: required, mediaTypes: mediaTypes, schema: schema}, nil
+}
+
+// compileContent finds the media types in a content object, and the schema of
+// the JSON media type among them, if there is one. The media type
+// "application/json" is preferred if there are several JSON media types.
+func (c *compiler) compileContent(value interface{}, tokens []string) ([]string, *url.URL) {
+	content, _ := value.(map[string]interface{})
+	mediaTypes := sortedKeys(content)
+
+	chosen := ""
+	for _, mediaType := range mediaTypes {
+		if mediaType == "application/json" || (chosen == "" && jsonbody.IsJSON(mediaType)) {
+			chosen = mediaType
+		}
+	}
+
+	if chosen == "" {
+		return mediaTypes, nil
+	}
+
+	mediaTypeObject, _ := content[chosen].(map[string]interface{})
+	schema, ok := mediaTypeObject["schema"]
+	if !ok {
+		return mediaTypes, nil
+	}
+
+	uri := c.addSchema(schema, appendTokens(tokens, chosen, "schema"))
+	return mediaTypes, &uri
+}
+
+// addSchema records that the schema at tokens must be compiled, and returns
+// the URI it will be known by.
+func (c *compiler) addSchema(schema interface{}, tokens []string) url.URL {
+	if c.dialect30 {
+		normalizeSchema(schema)
+	}
+
+	uri := url.URL{Fragment: jsonpointer.Ptr{Tokens: tokens}.String()}
+	c.uris = append(c.uris, uri)
+	return uri
+}
+
+// resolve follows any "$ref"-s from value, which is at tokens, returning the
+// object they lead to and where it is in the document.
+func (c *compiler) resolve(value interface{}, tokens []string) (map[string]interface{}, []string, error) {
+	for i := 0; i < maxRefDepth; i++ {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("openapi: %s is not an object", jsonpointer.Ptr{Tokens: tokens}.String())
+		}
+
+		ref, ok := object["$ref"].(string)
+		if !ok {
+			return object, tokens, nil
+		}
+
+		if !strings.HasPrefix(ref, "#") {
+			return nil, nil, fmt.Errorf("openapi: external reference not supported: %s", ref)
+		}
+
+		refURI, err := url.Parse(ref)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		ptr, err := jsonpointer.New(refURI.Fragment)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		target, err := ptr.Eval(c.root)
+		if err != nil {
+			return nil, nil, fmt.Errorf("openapi: unresolvable reference: %s", ref)
+		}
+
+		value = *target
+		tokens = ptr.Tokens
+	}
+
+	return nil, nil, fmt.Errorf("openapi: too many references at %s", jsonpointer.Ptr{Tokens: tokens}.String())
+}
+
+// schemaType finds the type declared by a schema. Only the first type other
+// than "null" is used.
+func (c *compiler) schemaType(schema interface{}) string {
+	object, _, err := c.resolve(schema, nil)
+	if err != nil {
+		return ""
+	}
+
+	return firstType(object["type"])
+}
+
+func firstType(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case []interface{}:
+		for _, elem := range value {
+			if s, ok := elem.(string); ok && s != "null" {
+				return s
+			}
+		}
+	}
+
+	return ""
+}
+
+// compilePath splits a path template into segments.
+func compilePath(path string) ([]segment, error) {
+	segments := []segment{}
+	for _, part := range strings.Split(strings.Trim(path, "/"), "/") {
+		if !strings.Contains(part, "{") {
+			segments = append(segments, segment{literal: part})
+			continue
+		}
+
+		// templates may occupy part of a segment, as in "{name}.json"
+		pattern := "^"
+		names := []string{}
+		rest := part
+		for {
+			start := strings.Index(rest, "{")
+			if start < 0 {
+				pattern += regexp.QuoteMeta(rest)
+				break
+			}
+
+			end := strings.Index(rest[start:], "}")
+			if end < 0 {
+				return nil, fmt.Errorf("openapi: invalid path template: %s", path)
+			}
+
+			pattern += regexp.QuoteMeta(rest[:start]) + "(.+?)"
+			names = append(names, rest[start+1:start+end])
+			rest = rest[start+end+1:]
+		}
+
+		re, err := regexp.Compile(pattern + "$")
+		if err != nil {
+			return nil, err
+		}
+
+		segments = append(segments, segment{pattern: re, names: names})
+	}
+
+	return segments, nil
+}
+
+func templateCount(segments []segment) int {
+	count := 0
+	for _, segment := range segments {
+		if segment.pattern != nil {
+			count++
+		}
+	}
+
+	return count
+}
+
+// serverPrefixes returns the paths of the servers of a document.
+func serverPrefixes(root map[string]interface{}) []string {
+	prefixes := []string{}
+	servers, _ := root["servers"].([]interface{})
+	for _, server := range servers {
+		object, _ := server.(map[string]interface{})
+		serverURL, _ := object["url"].(string)
+
+		parsed, err := url.Parse(serverURL)
+		if err != nil {
+			continue
+		}
+
+		if prefix := strings.TrimSuffix(parsed.Path, "/"); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	return prefixes
+}
+
+func appendTokens(tokens []string, more ...string) []string {
+	return append(append([]string{}, tokens...), more...)
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(value))
+		for key, elem := range value {
+			object[key] = deepCopy(elem)
+		}
+
+		return object
+	case []interface{}:
+		array := make([]interface{}, len(value))
+		for i, elem := range value {
+			array[i] = deepCopy(elem)
+		}
+
+		return array
+	default:
+		return value
+	}
+}
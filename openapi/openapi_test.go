@@ -0,0 +1,495 @@
+package openapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	jsonpointer "github.com/json-schema-spec/json-pointer-go"
+	jsonschema "github.com/json-schema-spec/json-schema-go"
+	"github.com/stretchr/testify/assert"
+)
+
+const testDocument = `
+openapi: 3.0.3
+info:
+  title: Users
+  version: "1"
+servers:
+  - url: https://api.example.com/v1
+paths:
+  /users:
+    get:
+      parameters:
+        - name: limit
+          in: query
+          schema: { type: integer, minimum: 1, maximum: 100 }
+        - name: tags
+          in: query
+          schema: { type: array, items: { type: string, maxLength: 3 } }
+        - name: active
+          in: query
+          schema: { type: boolean }
+        - $ref: "#/components/parameters/RequestID"
+      responses:
+        "200":
+          description: OK
+          content:
+            application/json:
+              schema:
+                type: array
+                items: { $ref: "#/components/schemas/User" }
+        default:
+          description: Error
+          content:
+            application/problem+json:
+              schema: { required: [title] }
+    post:
+      requestBody:
+        $ref: "#/components/requestBodies/User"
+      responses:
+        2XX:
+          description: Created
+  /users/me:
+    get:
+      responses:
+        "200":
+          description: OK
+  /users/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        schema: { type: integer, exclusiveMinimum: true, minimum: 0 }
+    get:
+      responses:
+        "200":
+          description: OK
+  /files/{name}.json:
+    get:
+      parameters:
+        - name: name
+          in: path
+          schema: { type: string, pattern: "^[a-z]+$" }
+      responses:
+        "200":
+          description: OK
+components:
+  parameters:
+    RequestID:
+      name: X-Request-ID
+      in: header
+      required: true
+      schema: { type: string }
+  requestBodies:
+    User:
+      required: true
+      content:
+        application/json:
+          schema: { $ref: "#/components/schemas/User" }
+  schemas:
+    User:
+      required: [name]
+      properties:
+        name: { type: string }
+        nickname: { type: string, nullable: true }
+`
+
+func newTestValidator(t *testing.T) *Validator {
+	validator, err := Load([]byte(testDocument))
+	assert.NoError(t, err)
+	return validator
+}
+
+func TestValidateRequest(t *testing.T) {
+	validator := newTestValidator(t)
+
+	testCases := []struct {
+		name    string
+		method  string
+		target  string
+		headers map[string]string
+		body    string
+		errors  []string
+		err     error
+	}{
+		{
+			"valid query",
+			"GET",
+			"/users?limit=10&tags=a&tags=bc&active=true",
+			map[string]string{"X-Request-ID": "abc"},
+			"",
+			[]string{},
+			nil,
+		},
+		{
+			"coerced query",
+			"GET",
+			"/users?limit=0&tags=a&tags=toolong&active=yes",
+			map[string]string{"X-Request-ID": "abc"},
+			"",
+			[]string{
+				`query "limit": : rejected by #/paths/~1users/get/parameters/0/schema/minimum`,
+				`query "tags": /1: rejected by #/paths/~1users/get/parameters/1/schema/items/maxLength`,
+				`query "active": : rejected by #/paths/~1users/get/parameters/2/schema/type`,
+			},
+			nil,
+		},
+		{
+			"numbers JSON cannot represent",
+			"GET",
+			"/users?limit=Infinity",
+			map[string]string{"X-Request-ID": "abc"},
+			"",
+			[]string{`query "limit": : rejected by #/paths/~1users/get/parameters/0/schema/type`},
+			nil,
+		},
+		{
+			"missing header",
+			"GET",
+			"/users",
+			nil,
+			"",
+			[]string{`header "X-Request-ID": required parameter is missing`},
+			nil,
+		},
+		{
+			"server prefix",
+			"GET",
+			"/v1/users?limit=abc",
+			map[string]string{"X-Request-ID": "abc"},
+			"",
+			[]string{`query "limit": : rejected by #/paths/~1users/get/parameters/0/schema/type`},
+			nil,
+		},
+		{
+			"path parameter",
+			"GET",
+			"/users/0",
+			nil,
+			"",
+			[]string{`path "id": : rejected by #/paths/~1users~1%7Bid%7D/parameters/0/schema/exclusiveMinimum`},
+			nil,
+		},
+		{
+			"concrete path preferred",
+			"GET",
+			"/users/me",
+			nil,
+			"",
+			[]string{},
+			nil,
+		},
+		{
+			"partial template",
+			"GET",
+			"/files/Report.json",
+			nil,
+			"",
+			[]string{`path "name": : rejected by #/paths/~1files~1%7Bname%7D.json/get/parameters/0/schema/pattern`},
+			nil,
+		},
+		{
+			"valid body",
+			"POST",
+			"/users",
+			map[string]string{"Content-Type": "application/json"},
+			`{"name": "alice", "nickname": null}`,
+			[]string{},
+			nil,
+		},
+		{
+			"invalid body",
+			"POST",
+			"/users",
+			map[string]string{"Content-Type": "application/json"},
+			`{"nickname": 3}`,
+			[]string{
+				`requestBody: : rejected by #/components/schemas/User/required/0`,
+				`requestBody: /nickname: rejected by #/components/schemas/User/properties/nickname/type`,
+			},
+			nil,
+		},
+		{
+			"missing body",
+			"POST",
+			"/users",
+			nil,
+			"",
+			[]string{`requestBody: required request body is missing`},
+			nil,
+		},
+		{
+			"malformed body",
+			"POST",
+			"/users",
+			nil,
+			`{"name": `,
+			[]string{`requestBody: body is malformed: unexpected EOF`},
+			nil,
+		},
+		{
+			"wrong media type",
+			"POST",
+			"/users",
+			map[string]string{"Content-Type": "text/plain"},
+			`alice`,
+			[]string{`requestBody: media type "text/plain" is not allowed`},
+			nil,
+		},
+		{
+			"no operation",
+			"DELETE",
+			"/users",
+			nil,
+			"",
+			nil,
+			ErrNoOperation,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+
+			result, err := validator.ValidateRequest(req)
+			assert.Equal(t, tt.err, err)
+			if err != nil {
+				return
+			}
+
+			errors := []string{}
+			for _, e := range result.Errors {
+				errors = append(errors, e.Error())
+			}
+
+			assert.Equal(t, tt.errors, errors)
+			assert.Equal(t, len(tt.errors) == 0, result.IsValid())
+
+			// the body can still be read
+			body, err := ioutil.ReadAll(req.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.body, string(body))
+		})
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	validator := newTestValidator(t)
+
+	testCases := []struct {
+		name        string
+		method      string
+		status      int
+		contentType string
+		body        string
+		errors      []string
+	}{
+		{"valid", "GET", 200, "application/json", `[{"name": "alice"}]`, []string{}},
+		{"invalid", "GET", 200, "application/json", `[{}]`, []string{`response: /0: rejected by #/components/schemas/User/required/0`}},
+		{"default", "GET", 500, "application/problem+json", `{}`, []string{`response: : rejected by #/paths/~1users/get/responses/default/content/application~1problem+json/schema/required/0`}},
+		{"range", "POST", 201, "", ``, []string{}},
+		{"undocumented", "POST", 400, "", ``, []string{`response: status 400 is not documented`}},
+		{"not json", "GET", 200, "text/plain", `hello`, []string{}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/users", nil)
+
+			rec := httptest.NewRecorder()
+			if tt.contentType != "" {
+				rec.Header().Set("Content-Type", tt.contentType)
+			}
+
+			rec.WriteHeader(tt.status)
+			rec.WriteString(tt.body)
+
+			result, err := validator.ValidateResponse(req, rec.Result())
+			assert.NoError(t, err)
+
+			errors := []string{}
+			for _, e := range result.Errors {
+				errors = append(errors, e.Error())
+			}
+
+			assert.Equal(t, tt.errors, errors)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	validator := newTestValidator(t)
+
+	req := httptest.NewRequest("GET", "/users?limit=500", nil)
+	req.Header.Set("X-Request-ID", "abc")
+
+	resp := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(`{}`)),
+	}
+
+	result, err := validator.Validate(req, resp)
+	assert.NoError(t, err)
+	assert.Equal(t, []Error{
+		Error{
+			In:   InQuery,
+			Name: "limit",
+			ValidationError: &jsonschema.ValidationError{
+				InstancePath: jsonpointer.Ptr{Tokens: []string{}},
+				SchemaPath:   jsonpointer.Ptr{Tokens: []string{"paths", "/users", "get", "parameters", "0", "schema", "maximum"}},
+				URI:          url.URL{Fragment: "/paths/~1users/get/parameters/0/schema"},
+			},
+		},
+		Error{
+			In: InResponse,
+			ValidationError: &jsonschema.ValidationError{
+				InstancePath: jsonpointer.Ptr{Tokens: []string{}},
+				SchemaPath:   jsonpointer.Ptr{Tokens: []string{"paths", "/users", "get", "responses", "200", "content", "application/json", "schema", "type"}},
+				URI:          url.URL{Fragment: "/paths/~1users/get/responses/200/content/application~1json/schema"},
+			},
+		},
+	}, result.Errors)
+}
+
+func TestValidateRequestParameterStyles(t *testing.T) {
+	validator, err := Load([]byte(`
+openapi: 3.0.3
+info:
+  title: Styles
+  version: "1"
+paths:
+  /items:
+    get:
+      parameters:
+        - name: ids
+          in: query
+          style: pipeDelimited
+          explode: false
+          schema: { type: array, items: { type: integer } }
+        - name: names
+          in: query
+          style: spaceDelimited
+          explode: false
+          schema: { type: array, items: { type: string, maxLength: 1 } }
+        - name: X-Flags
+          in: header
+          schema: { type: array, items: { type: boolean } }
+        - name: content-type
+          in: header
+          required: true
+          schema: { type: string, const: application/xml }
+        - name: Accept
+          in: header
+          required: true
+        - name: Authorization
+          in: header
+          required: true
+      responses:
+        "200":
+          description: OK
+`))
+	assert.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/items?ids=1|2|3&names=a%20b", nil)
+	req.Header.Set("X-Flags", "true,false")
+	req.Header.Set("Content-Type", "application/json")
+
+	result, err := validator.ValidateRequest(req)
+	assert.NoError(t, err)
+	assert.Equal(t, []Error{}, result.Errors)
+
+	req = httptest.NewRequest("GET", "/items?ids=1|x&names=a%20bc", nil)
+	req.Header.Set("X-Flags", "true,yes")
+
+	result, err = validator.ValidateRequest(req)
+	assert.NoError(t, err)
+
+	errors := []string{}
+	for _, e := range result.Errors {
+		errors = append(errors, e.Error())
+	}
+
+	assert.Equal(t, []string{
+		`query "ids": /1: rejected by #/paths/~1items/get/parameters/0/schema/items/type`,
+		`query "names": /1: rejected by #/paths/~1items/get/parameters/1/schema/items/maxLength`,
+		`header "X-Flags": /1: rejected by #/paths/~1items/get/parameters/2/schema/items/type`,
+	}, errors)
+}
+
+func TestNewUnsupportedStyle(t *testing.T) {
+	testCases := []struct {
+		in    string
+		style string
+		err   string
+	}{
+		{"path", "matrix", `openapi: /paths/~1a~1{id}/get/parameters/0: unsupported style "matrix" for path parameter`},
+		{"path", "label", `openapi: /paths/~1a~1{id}/get/parameters/0: unsupported style "label" for path parameter`},
+		{"query", "deepObject", `openapi: /paths/~1a~1{id}/get/parameters/0: unsupported style "deepObject" for query parameter`},
+		{"header", "form", `openapi: /paths/~1a~1{id}/get/parameters/0: unsupported style "form" for header parameter`},
+		{"cookie", "pipeDelimited", `openapi: /paths/~1a~1{id}/get/parameters/0: unsupported style "pipeDelimited" for cookie parameter`},
+		{"query", "commaDelimited", `openapi: /paths/~1a~1{id}/get/parameters/0: unsupported style "commaDelimited" for query parameter`},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.in+" "+tt.style, func(t *testing.T) {
+			_, err := New(map[string]interface{}{
+				"openapi": "3.0.0",
+				"paths": map[string]interface{}{
+					"/a/{id}": map[string]interface{}{
+						"get": map[string]interface{}{
+							"parameters": []interface{}{
+								map[string]interface{}{"name": "id", "in": tt.in, "style": tt.style},
+							},
+						},
+					},
+				},
+			})
+
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(map[string]interface{}{"swagger": "2.0"})
+	assert.Error(t, err)
+
+	_, err = New([]interface{}{})
+	assert.Error(t, err)
+
+	_, err = New(map[string]interface{}{
+		"openapi": "3.0.0",
+		"paths": map[string]interface{}{
+			"/a": map[string]interface{}{
+				"get": map[string]interface{}{
+					"parameters": []interface{}{
+						map[string]interface{}{"$ref": "other.yaml#/components/parameters/A"},
+					},
+				},
+			},
+		},
+	})
+	assert.Error(t, err)
+
+	// the document given is not modified
+	document := map[string]interface{}{
+		"openapi": "3.0.0",
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"A": map[string]interface{}{"type": "string", "nullable": true},
+			},
+		},
+	}
+
+	_, err = New(document)
+	assert.NoError(t, err)
+	assert.Equal(t, true, document["components"].(map[string]interface{})["schemas"].(map[string]interface{})["A"].(map[string]interface{})["nullable"])
+}
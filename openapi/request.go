@@ -0,0 +1,367 @@
+package openapi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	jsonschema "github.com/json-schema-spec/json-schema-go"
+	"github.com/json-schema-spec/json-schema-go/internal/jsonbody"
+)
+
+// Locations of errors within a request or response.
+const (
+	InPath        = "path"
+	InQuery       = "query"
+	InHeader      = "header"
+	InCookie      = "cookie"
+	InRequestBody = "requestBody"
+	InResponse    = "response"
+)
+
+// Result contains the errors found validating a request or response.
+type Result struct {
+	// Errors is the list of errors found, in the order parameters, request
+	// body, response.
+	Errors []Error
+}
+
+// IsValid checks whether no errors were found.
+func (r Result) IsValid() bool {
+	return len(r.Errors) == 0
+}
+
+// add appends the errors from validating part of a request or response.
+func (r *Result) add(in, name string, validationResult jsonschema.ValidationResult) {
+	for i := range validationResult.Errors {
+		r.Errors = append(r.Errors, Error{In: in, Name: name, ValidationError: &validationResult.Errors[i]})
+	}
+}
+
+// Error is a problem with part of a request or response.
+type Error struct {
+	// In is which part of the request or response has the problem, such as
+	// InQuery or InRequestBody.
+	In string
+
+	// Name is the name of the parameter with the problem, for errors in
+	// parameters.
+	Name string
+
+	// Message describes the problem, for problems which were not found by a
+	// schema, such as a missing required parameter.
+	Message string
+
+	// ValidationError is the error from the schema which rejected this part
+	// of the request or response, if the problem was found by a schema.
+	ValidationError *jsonschema.ValidationError
+}
+
+// Error fulfills the error interface.
+func (e Error) Error() string {
+	location := e.In
+	if e.Name != "" {
+		location += " " + strconv.Quote(e.Name)
+	}
+
+	if e.ValidationError == nil {
+		return fmt.Sprintf("%s: %s", location, e.Message)
+	}
+
+	schemaLocation := e.ValidationError.URI
+	schemaLocation.Fragment = e.ValidationError.SchemaPath.String()
+	return fmt.Sprintf("%s: %s: rejected by %s", location, e.ValidationError.InstancePath.String(), schemaLocation.String())
+}
+
+// Validate validates a request, and the response to it if resp is not nil.
+//
+// If no operation matches the request, ErrNoOperation is returned. Errors from
+// evaluating schemas, such as jsonschema.ErrStackOverflow, are also returned.
+func (v *Validator) Validate(req *http.Request, resp *http.Response) (Result, error) {
+	result, err := v.ValidateRequest(req)
+	if err != nil || resp == nil {
+		return result, err
+	}
+
+	respResult, err := v.ValidateResponse(req, resp)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result.Errors = append(result.Errors, respResult.Errors...)
+	return result, nil
+}
+
+// ValidateRequest validates the parameters and body of a request against the
+// operation it matches.
+//
+// Path, query, header and cookie parameters are strings, so array parameters
+// are first split according to their style, and then the values are coerced
+// into the types their schema declares, as jsonschema.CoerceAndValidate does.
+// Values which cannot be coerced are left as strings, for the schema to
+// reject.
+//
+// The body of req is read in full, and replaced so that it may be read again.
+func (v *Validator) ValidateRequest(req *http.Request) (Result, error) {
+	op, pathParams, ok := v.match(req)
+	if !ok {
+		return Result{}, ErrNoOperation
+	}
+
+	result := Result{Errors: []Error{}}
+	for _, param := range op.parameters {
+		values := paramValues(req, param, pathParams)
+		if len(values) == 0 {
+			if param.required {
+				result.Errors = append(result.Errors, Error{In: param.in, Name: param.name, Message: "required parameter is missing"})
+			}
+
+			continue
+		}
+
+		if param.schema == nil {
+			continue
+		}
+
+		_, validationResult, err := v.validator.CoerceAndValidateURIContext(req.Context(), *param.schema, split(param, values))
+		if err != nil {
+			return Result{}, err
+		}
+
+		result.add(param.in, param.name, validationResult)
+	}
+
+	if op.requestBody == nil {
+		return result, nil
+	}
+
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if len(body) == 0 {
+		if op.requestBody.required {
+			result.Errors = append(result.Errors, Error{In: InRequestBody, Message: "required request body is missing"})
+		}
+
+		return result, nil
+	}
+
+	contentType := req.Header.Get("Content-Type")
+	if !mediaTypeAllowed(contentType, op.requestBody.mediaTypes) {
+		result.Errors = append(result.Errors, Error{In: InRequestBody, Message: fmt.Sprintf("media type %q is not allowed", contentType)})
+		return result, nil
+	}
+
+	if op.requestBody.schema == nil || !jsonbody.IsJSON(contentType) {
+		return result, nil
+	}
+
+	err = v.validateBody(req, &result, InRequestBody, *op.requestBody.schema, body)
+	return result, err
+}
+
+// ValidateResponse validates a response against the operation the request it
+// responds to matches.
+//
+// The body of resp is read in full, and replaced so that it may be read again.
+func (v *Validator) ValidateResponse(req *http.Request, resp *http.Response) (Result, error) {
+	op, _, ok := v.match(req)
+	if !ok {
+		return Result{}, ErrNoOperation
+	}
+
+	result := Result{Errors: []Error{}}
+
+	status := strconv.Itoa(resp.StatusCode)
+	response, ok := op.responses[status]
+	if !ok {
+		response, ok = op.responses[fmt.Sprintf("%dXX", resp.StatusCode/100)]
+	}
+
+	if !ok {
+		response, ok = op.responses["DEFAULT"]
+	}
+
+	if !ok {
+		result.Errors = append(result.Errors, Error{In: InResponse, Message: fmt.Sprintf("status %s is not documented", status)})
+		return result, nil
+	}
+
+	if response.schema == nil || !jsonbody.IsJSON(resp.Header.Get("Content-Type")) {
+		return result, nil
+	}
+
+	body, err := readBody(&resp.Body)
+	if err != nil {
+		return Result{}, err
+	}
+
+	err = v.validateBody(req, &result, InResponse, *response.schema, body)
+	return result, err
+}
+
+// match finds the operation for a request, along with the values of its path
+// parameters.
+func (v *Validator) match(req *http.Request) (*operation, map[string]string, bool) {
+	paths := []string{req.URL.EscapedPath()}
+	for _, prefix := range v.prefixes {
+		if strings.HasPrefix(paths[0], prefix+"/") {
+			paths = append(paths, strings.TrimPrefix(paths[0], prefix))
+		}
+	}
+
+	for _, path := range paths {
+		parts := strings.Split(strings.Trim(path, "/"), "/")
+
+		for _, op := range v.operations {
+			if op.method != req.Method {
+				continue
+			}
+
+			if params, ok := matchSegments(op.segments, parts); ok {
+				return op, params, true
+			}
+		}
+	}
+
+	return nil, nil, false
+}
+
+func matchSegments(segments []segment, parts []string) (map[string]string, bool) {
+	if len(segments) != len(parts) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, segment := range segments {
+		part, err := url.PathUnescape(parts[i])
+		if err != nil {
+			return nil, false
+		}
+
+		if segment.pattern == nil {
+			if segment.literal != part {
+				return nil, false
+			}
+
+			continue
+		}
+
+		matches := segment.pattern.FindStringSubmatch(part)
+		if matches == nil {
+			return nil, false
+		}
+
+		for j, name := range segment.names {
+			params[name] = matches[j+1]
+		}
+	}
+
+	return params, true
+}
+
+// paramValues finds the raw values of a parameter in a request.
+func paramValues(req *http.Request, param parameter, pathParams map[string]string) []string {
+	switch param.in {
+	case "path":
+		if value, ok := pathParams[param.name]; ok {
+			return []string{value}
+		}
+	case "query":
+		return req.URL.Query()[param.name]
+	case "header":
+		return req.Header[http.CanonicalHeaderKey(param.name)]
+	case "cookie":
+		if cookie, err := req.Cookie(param.name); err == nil {
+			return []string{cookie.Value}
+		}
+	}
+
+	return nil
+}
+
+// split converts the raw values of a parameter into the value its schema is
+// applied to. Array parameters are split into their elements, and other
+// parameters use their first value.
+func split(param parameter, values []string) interface{} {
+	if param.schemaType != "array" {
+		return values[0]
+	}
+
+	if !param.explode {
+		values = strings.Split(values[0], param.separator)
+	}
+
+	array := make([]interface{}, len(values))
+	for i, value := range values {
+		array[i] = value
+	}
+
+	return array
+}
+
+func (v *Validator) validateBody(req *http.Request, result *Result, in string, uri url.URL, body []byte) error {
+	instance, err := jsonbody.Decode(body)
+	if err != nil {
+		result.Errors = append(result.Errors, Error{In: in, Message: fmt.Sprintf("body is malformed: %v", err)})
+		return nil
+	}
+
+	validationResult, err := v.validator.ValidateURIContext(req.Context(), uri, instance)
+	if err != nil {
+		return err
+	}
+
+	result.add(in, "", validationResult)
+	return nil
+}
+
+// readBody reads a body in full, replacing it so that it can be read again.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+
+	*body = ioutil.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// mediaTypeAllowed checks whether a Content-Type matches one of the media
+// types of a content object, which may have wildcards such as "image/*".
+func mediaTypeAllowed(contentType string, allowed []string) bool {
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(pattern)
+		if pattern == mediaType || pattern == "*/*" {
+			return true
+		}
+
+		if strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -5,26 +5,29 @@ import (
 	"math"
 	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 
 	jsonpointer "github.com/json-schema-spec/json-pointer-go"
 )
 
 type parser struct {
-	registry *registry
-	baseURI  url.URL
-	tokens   []string
+	registry       *registry
+	integerEpsilon float64
+	baseURI        url.URL
+	tokens         []string
 }
 
-func parseRootSchema(registry *registry, input interface{}) (schema, error) {
-	return parseSubSchema(registry, url.URL{}, []string{}, input)
+func parseRootSchema(registry *registry, integerEpsilon float64, input interface{}) (schema, error) {
+	return parseSubSchema(registry, integerEpsilon, url.URL{}, []string{}, input)
 }
 
-func parseSubSchema(registry *registry, baseURI url.URL, tokens []string, input interface{}) (schema, error) {
+func parseSubSchema(registry *registry, integerEpsilon float64, baseURI url.URL, tokens []string, input interface{}) (schema, error) {
 	p := parser{
-		registry: registry,
-		tokens:   tokens,
-		baseURI:  baseURI,
+		registry:       registry,
+		integerEpsilon: integerEpsilon,
+		tokens:         tokens,
+		baseURI:        baseURI,
 	}
 
 	index, err := p.Parse(input)
@@ -250,8 +253,7 @@ func (p *parser) Parse(input interface{}) (int, error) {
 				return -1, ErrInvalidSchema
 			}
 
-			s.Enum.IsSet = true
-			s.Enum.Values = enumArray
+			s.Enum = newSchemaEnum(enumArray)
 		}
 
 		multipleOfValue, ok := input["multipleOf"]
@@ -261,8 +263,13 @@ func (p *parser) Parse(input interface{}) (int, error) {
 				return -1, ErrInvalidSchema
 			}
 
+			if multipleOfNumber <= 0 {
+				return -1, ErrInvalidSchema
+			}
+
 			s.MultipleOf.IsSet = true
 			s.MultipleOf.Value = multipleOfNumber
+			s.MultipleOf.Decimal = decimalFromFloat(multipleOfNumber)
 		}
 
 		maximumValue, ok := input["maximum"]
@@ -316,11 +323,11 @@ func (p *parser) Parse(input interface{}) (int, error) {
 				return -1, ErrInvalidSchema
 			}
 
-			maxLengthInt, rem := math.Modf(maxLengthNumber)
-			if rem > Epsilon {
+			if !isInteger(maxLengthNumber, p.integerEpsilon) {
 				return -1, ErrInvalidSchema
 			}
 
+			maxLengthInt := math.Round(maxLengthNumber)
 			if maxLengthInt < 0 {
 				return -1, ErrInvalidSchema
 			}
@@ -336,11 +343,11 @@ func (p *parser) Parse(input interface{}) (int, error) {
 				return -1, ErrInvalidSchema
 			}
 
-			minLengthInt, rem := math.Modf(minLengthNumber)
-			if rem > Epsilon {
+			if !isInteger(minLengthNumber, p.integerEpsilon) {
 				return -1, ErrInvalidSchema
 			}
 
+			minLengthInt := math.Round(minLengthNumber)
 			if minLengthInt < 0 {
 				return -1, ErrInvalidSchema
 			}
@@ -387,11 +394,11 @@ func (p *parser) Parse(input interface{}) (int, error) {
 				return -1, ErrInvalidSchema
 			}
 
-			maxItemsInt, rem := math.Modf(maxItemsNumber)
-			if rem > Epsilon {
+			if !isInteger(maxItemsNumber, p.integerEpsilon) {
 				return -1, ErrInvalidSchema
 			}
 
+			maxItemsInt := math.Round(maxItemsNumber)
 			if maxItemsInt < 0 {
 				return -1, ErrInvalidSchema
 			}
@@ -407,11 +414,11 @@ func (p *parser) Parse(input interface{}) (int, error) {
 				return -1, ErrInvalidSchema
 			}
 
-			minItemsInt, rem := math.Modf(minItemsNumber)
-			if rem > Epsilon {
+			if !isInteger(minItemsNumber, p.integerEpsilon) {
 				return -1, ErrInvalidSchema
 			}
 
+			minItemsInt := math.Round(minItemsNumber)
 			if minItemsInt < 0 {
 				return -1, ErrInvalidSchema
 			}
@@ -453,11 +460,11 @@ func (p *parser) Parse(input interface{}) (int, error) {
 				return -1, ErrInvalidSchema
 			}
 
-			maxPropertiesInt, rem := math.Modf(maxPropertiesNumber)
-			if rem > Epsilon {
+			if !isInteger(maxPropertiesNumber, p.integerEpsilon) {
 				return -1, ErrInvalidSchema
 			}
 
+			maxPropertiesInt := math.Round(maxPropertiesNumber)
 			if maxPropertiesInt < 0 {
 				return -1, ErrInvalidSchema
 			}
@@ -473,11 +480,11 @@ func (p *parser) Parse(input interface{}) (int, error) {
 				return -1, ErrInvalidSchema
 			}
 
-			minPropertiesInt, rem := math.Modf(minPropertiesNumber)
-			if rem > Epsilon {
+			if !isInteger(minPropertiesNumber, p.integerEpsilon) {
 				return -1, ErrInvalidSchema
 			}
 
+			minPropertiesInt := math.Round(minPropertiesNumber)
 			if minPropertiesInt < 0 {
 				return -1, ErrInvalidSchema
 			}
@@ -517,9 +524,9 @@ func (p *parser) Parse(input interface{}) (int, error) {
 			p.Push("properties")
 
 			schemas := map[string]int{}
-			for property, elem := range propertiesObject {
+			for _, property := range sortedKeys(propertiesObject) {
 				p.Push(property)
-				subSchema, err := p.Parse(elem)
+				subSchema, err := p.Parse(propertiesObject[property])
 				if err != nil {
 					return -1, err
 				}
@@ -544,20 +551,23 @@ func (p *parser) Parse(input interface{}) (int, error) {
 
 			p.Push("patternProperties")
 
-			schemas := map[*regexp.Regexp]int{}
-			for property, elem := range patternPropertiesObject {
+			schemas := []schemaPatternProperty{}
+			for _, property := range sortedKeys(patternPropertiesObject) {
 				propertyRegexp, err := regexp.Compile(property)
 				if err != nil {
 					return -1, ErrInvalidSchema
 				}
 
 				p.Push(property)
-				subSchema, err := p.Parse(elem)
+				subSchema, err := p.Parse(patternPropertiesObject[property])
 				if err != nil {
 					return -1, err
 				}
 
-				schemas[propertyRegexp] = subSchema
+				schemas = append(schemas, schemaPatternProperty{
+					Pattern: propertyRegexp,
+					Schema:  subSchema,
+				})
 
 				p.Pop()
 			}
@@ -592,11 +602,11 @@ func (p *parser) Parse(input interface{}) (int, error) {
 
 			p.Push("dependencies")
 
-			dependencies := map[string]schemaDependency{}
-			for key, value := range dependenciesObject {
+			dependencies := []schemaDependency{}
+			for _, key := range sortedKeys(dependenciesObject) {
 				p.Push(key)
 
-				switch val := value.(type) {
+				switch val := dependenciesObject[key].(type) {
 				case []interface{}:
 					properties := []string{}
 					for _, property := range val {
@@ -608,20 +618,22 @@ func (p *parser) Parse(input interface{}) (int, error) {
 						properties = append(properties, propertyString)
 					}
 
-					dependencies[key] = schemaDependency{
+					dependencies = append(dependencies, schemaDependency{
+						Property:   key,
 						IsSchema:   false,
 						Properties: properties,
-					}
+					})
 				default:
 					subSchema, err := p.Parse(val)
 					if err != nil {
 						return -1, err
 					}
 
-					dependencies[key] = schemaDependency{
+					dependencies = append(dependencies, schemaDependency{
+						Property: key,
 						IsSchema: true,
 						Schema:   subSchema,
-					}
+					})
 				}
 
 				p.Pop()
@@ -753,3 +765,16 @@ func parseJSONType(typ string) (jsonType, error) {
 		return 0, ErrInvalidSchema
 	}
 }
+
+// sortedKeys returns the keys of an object in lexicographic order. Go does not
+// preserve the order of keys in a map, so this is used wherever the order in
+// which properties are visited is observable.
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,296 @@
+package jsonschema
+
+// program is the compiled form of the schemas in an arena, and is what the vm
+// evaluates instances against.
+//
+// Each schema in the arena has a node in the program at the same index. A
+// node's instructions cover only the keywords its schema has, and are split by
+// the kind of instance they apply to, so evaluating an instance never
+// considers keywords which cannot affect it.
+type program struct {
+	nodes []node
+}
+
+// node is the compiled form of a single schema.
+type node struct {
+	// schema points to the schema in the arena, which holds the values of its
+	// keywords. It is a pointer so that the schema need not be copied to be
+	// evaluated.
+	schema *schema
+
+	// common holds the instructions for keywords which apply to every kind of
+	// instance, in the order they are evaluated.
+	common []opcode
+
+	// typed holds, for each kind of instance, the instructions for keywords
+	// which apply only to that kind, in the order they are evaluated. They are
+	// evaluated after common.
+	typed [numInstanceKinds][]opcode
+
+	// sortKeys is whether the instructions for objects need the keys of the
+	// instance in sorted order.
+	sortKeys bool
+}
+
+// instanceKind is the kind of value an instance is, as far as deciding which
+// keywords apply to it is concerned.
+type instanceKind int
+
+const (
+	instanceKindNull instanceKind = iota
+	instanceKindBoolean
+	instanceKindNumber
+	instanceKindString
+	instanceKindArray
+	instanceKindObject
+
+	numInstanceKinds
+)
+
+// opcode is a single instruction of a node. Most opcodes evaluate the keyword
+// of the same name.
+type opcode uint8
+
+const (
+	// opTrue evaluates a schema of true.
+	opTrue opcode = iota + 1
+
+	// opFalse evaluates a schema of false.
+	opFalse
+
+	opRef
+	opNot
+	opIf
+	opConst
+	opEnum
+	opAllOf
+	opAnyOf
+	opOneOf
+
+	// opTypeAccept evaluates "type" when it allows the kind of instance.
+	opTypeAccept
+
+	// opTypeReject evaluates "type" when it does not allow the kind of
+	// instance.
+	opTypeReject
+
+	// opTypeInteger evaluates "type" when it allows integers but not other
+	// numbers.
+	opTypeInteger
+
+	opMultipleOf
+	opMaximum
+	opMinimum
+	opExclusiveMaximum
+	opExclusiveMinimum
+
+	opMaxLength
+	opMinLength
+	opPattern
+
+	opMaxItems
+	opMinItems
+	opUniqueItems
+	opContains
+
+	// opItems evaluates "items" when it is a single schema.
+	opItems
+
+	// opTupleItems evaluates "items" when it is an array of schemas.
+	opTupleItems
+
+	// opAdditionalItems evaluates "additionalItems". It is only emitted after
+	// opTupleItems.
+	opAdditionalItems
+
+	opMaxProperties
+	opMinProperties
+	opRequired
+
+	// opProperties evaluates "properties", "patternProperties" and
+	// "additionalProperties" together, as which of them apply to a member
+	// depends on the others.
+	opProperties
+
+	opDependencies
+	opPropertyNames
+)
+
+// compileProgram compiles the given schemas into a program. The program points
+// into schemas, which must not be modified afterwards.
+func compileProgram(schemas []schema) *program {
+	p := &program{nodes: make([]node, len(schemas))}
+	for i := range schemas {
+		p.nodes[i] = compileNode(&schemas[i])
+	}
+
+	return p
+}
+
+func compileNode(s *schema) node {
+	n := node{schema: s}
+
+	if s.Bool.IsSet {
+		if s.Bool.Value {
+			n.common = []opcode{opTrue}
+		} else {
+			n.common = []opcode{opFalse}
+		}
+
+		return n
+	}
+
+	common := []opcode{}
+	if s.Ref.IsSet {
+		common = append(common, opRef)
+	}
+	if s.Not.IsSet {
+		common = append(common, opNot)
+	}
+	if s.If.IsSet {
+		common = append(common, opIf)
+	}
+	if s.Const.IsSet {
+		common = append(common, opConst)
+	}
+	if s.Enum.IsSet {
+		common = append(common, opEnum)
+	}
+	if s.AllOf.IsSet {
+		common = append(common, opAllOf)
+	}
+	if s.AnyOf.IsSet {
+		common = append(common, opAnyOf)
+	}
+	if s.OneOf.IsSet {
+		common = append(common, opOneOf)
+	}
+
+	n.common = common
+
+	null := []opcode{}
+	if s.Type.IsSet {
+		null = append(null, compileType(s.Type, jsonTypeNull))
+	}
+
+	n.typed[instanceKindNull] = null
+
+	boolean := []opcode{}
+	if s.Type.IsSet {
+		boolean = append(boolean, compileType(s.Type, jsonTypeBoolean))
+	}
+
+	n.typed[instanceKindBoolean] = boolean
+
+	number := []opcode{}
+	if s.Type.IsSet {
+		switch {
+		case s.Type.contains(jsonTypeNumber):
+			number = append(number, opTypeAccept)
+		case s.Type.contains(jsonTypeInteger):
+			number = append(number, opTypeInteger)
+		default:
+			number = append(number, opTypeReject)
+		}
+	}
+	if s.MultipleOf.IsSet {
+		number = append(number, opMultipleOf)
+	}
+	if s.Maximum.IsSet {
+		number = append(number, opMaximum)
+	}
+	if s.Minimum.IsSet {
+		number = append(number, opMinimum)
+	}
+	if s.ExclusiveMaximum.IsSet {
+		number = append(number, opExclusiveMaximum)
+	}
+	if s.ExclusiveMinimum.IsSet {
+		number = append(number, opExclusiveMinimum)
+	}
+
+	n.typed[instanceKindNumber] = number
+
+	str := []opcode{}
+	if s.Type.IsSet {
+		str = append(str, compileType(s.Type, jsonTypeString))
+	}
+	if s.MaxLength.IsSet {
+		str = append(str, opMaxLength)
+	}
+	if s.MinLength.IsSet {
+		str = append(str, opMinLength)
+	}
+	if s.Pattern.IsSet {
+		str = append(str, opPattern)
+	}
+
+	n.typed[instanceKindString] = str
+
+	array := []opcode{}
+	if s.Type.IsSet {
+		array = append(array, compileType(s.Type, jsonTypeArray))
+	}
+	if s.MaxItems.IsSet {
+		array = append(array, opMaxItems)
+	}
+	if s.MinItems.IsSet {
+		array = append(array, opMinItems)
+	}
+	if s.UniqueItems.IsSet && s.UniqueItems.Value {
+		array = append(array, opUniqueItems)
+	}
+	if s.Contains.IsSet {
+		array = append(array, opContains)
+	}
+	if s.Items.IsSet {
+		if s.Items.IsSingle {
+			array = append(array, opItems)
+		} else {
+			array = append(array, opTupleItems)
+			if s.AdditionalItems.IsSet {
+				array = append(array, opAdditionalItems)
+			}
+		}
+	}
+
+	n.typed[instanceKindArray] = array
+
+	object := []opcode{}
+	if s.Type.IsSet {
+		object = append(object, compileType(s.Type, jsonTypeObject))
+	}
+	if s.MaxProperties.IsSet {
+		object = append(object, opMaxProperties)
+	}
+	if s.MinProperties.IsSet {
+		object = append(object, opMinProperties)
+	}
+	if s.Required.IsSet {
+		object = append(object, opRequired)
+	}
+	if s.Properties.IsSet || s.PatternProperties.IsSet || s.AdditionalProperties.IsSet {
+		object = append(object, opProperties)
+	}
+	if s.Dependencies.IsSet {
+		object = append(object, opDependencies)
+	}
+	if s.PropertyNames.IsSet {
+		object = append(object, opPropertyNames)
+	}
+
+	n.typed[instanceKindObject] = object
+	n.sortKeys = s.Properties.IsSet || s.PatternProperties.IsSet || s.AdditionalProperties.IsSet || s.PropertyNames.IsSet
+
+	return n
+}
+
+// compileType compiles "type" for instances of the given type, other than
+// numbers.
+func compileType(t schemaType, typ jsonType) opcode {
+	if t.contains(typ) {
+		return opTypeAccept
+	}
+
+	return opTypeReject
+}
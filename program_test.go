@@ -0,0 +1,96 @@
+package jsonschema
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileNode(t *testing.T) {
+	testCases := []struct {
+		name   string
+		schema interface{}
+		common []opcode
+		typed  [numInstanceKinds][]opcode
+	}{
+		{
+			"true",
+			true,
+			[]opcode{opTrue},
+			[numInstanceKinds][]opcode{},
+		},
+		{
+			"empty",
+			map[string]interface{}{},
+			[]opcode{},
+			[numInstanceKinds][]opcode{
+				instanceKindNull:    {},
+				instanceKindBoolean: {},
+				instanceKindNumber:  {},
+				instanceKindString:  {},
+				instanceKindArray:   {},
+				instanceKindObject:  {},
+			},
+		},
+		{
+			"type integer",
+			map[string]interface{}{"type": "integer", "minimum": 1.0, "maxLength": 3.0},
+			[]opcode{},
+			[numInstanceKinds][]opcode{
+				instanceKindNull:    {opTypeReject},
+				instanceKindBoolean: {opTypeReject},
+				instanceKindNumber:  {opTypeInteger, opMinimum},
+				instanceKindString:  {opTypeReject, opMaxLength},
+				instanceKindArray:   {opTypeReject},
+				instanceKindObject:  {opTypeReject},
+			},
+		},
+		{
+			"type number",
+			map[string]interface{}{"type": []interface{}{"integer", "number", "null"}},
+			[]opcode{},
+			[numInstanceKinds][]opcode{
+				instanceKindNull:    {opTypeAccept},
+				instanceKindBoolean: {opTypeReject},
+				instanceKindNumber:  {opTypeAccept},
+				instanceKindString:  {opTypeReject},
+				instanceKindArray:   {opTypeReject},
+				instanceKindObject:  {opTypeReject},
+			},
+		},
+		{
+			"applicators",
+			map[string]interface{}{
+				"allOf":                []interface{}{true},
+				"not":                  false,
+				"items":                []interface{}{true},
+				"additionalItems":      false,
+				"uniqueItems":          false,
+				"additionalProperties": false,
+				"required":             []interface{}{"a"},
+			},
+			[]opcode{opNot, opAllOf},
+			[numInstanceKinds][]opcode{
+				instanceKindNull:    {},
+				instanceKindBoolean: {},
+				instanceKindNumber:  {},
+				instanceKindString:  {},
+				instanceKindArray:   {opTupleItems, opAdditionalItems},
+				instanceKindObject:  {opRequired, opProperties},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			validator, err := NewValidator([]interface{}{tt.schema})
+			assert.NoError(t, err)
+
+			node := validator.program.nodes[validator.registry.schemas[url.URL{}]]
+
+			assert.Equal(t, tt.common, node.common)
+			assert.Equal(t, tt.typed, node.typed)
+		})
+	}
+}
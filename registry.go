@@ -50,3 +50,96 @@ func (r *registry) PopulateRefs() []url.URL {
 
 	return missing
 }
+
+// FindRefCycle looks for a cycle of "$ref"s which evaluation would follow
+// forever, without descending into the instance, and returns the URIs of the
+// "$ref"s making up the first such cycle found. It returns nil if there is no
+// such cycle.
+//
+// Only subschemas which are always evaluated against the same instance as
+// their parent are considered, so recursion which is guarded by a keyword like
+// "properties", or by an earlier "anyOf" branch, is allowed.
+func (r *registry) FindRefCycle() []url.URL {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	state := make([]int, len(r.arena.schemas))
+	path := []int{}
+
+	var visit func(index int) []int
+	visit = func(index int) []int {
+		state[index] = visiting
+		path = append(path, index)
+
+		for _, next := range sameInstanceSchemas(&r.arena.schemas[index]) {
+			switch state[next] {
+			case visiting:
+				for i, pathIndex := range path {
+					if pathIndex == next {
+						return path[i:]
+					}
+				}
+			case unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[index] = visited
+		return nil
+	}
+
+	for index := range r.arena.schemas {
+		if state[index] != unvisited {
+			continue
+		}
+
+		if cycle := visit(index); cycle != nil {
+			uris := []url.URL{}
+			for _, cycleIndex := range cycle {
+				if ref := r.arena.schemas[cycleIndex].Ref; ref.IsSet {
+					uris = append(uris, ref.URI)
+				}
+			}
+
+			return uris
+		}
+	}
+
+	return nil
+}
+
+// sameInstanceSchemas returns the subschemas of s which are evaluated against
+// the same instance as s whenever s is evaluated.
+func sameInstanceSchemas(s *schema) []int {
+	schemas := []int{}
+
+	if s.Ref.IsSet {
+		schemas = append(schemas, s.Ref.Schema)
+	}
+	if s.Not.IsSet {
+		schemas = append(schemas, s.Not.Schema)
+	}
+	if s.If.IsSet {
+		schemas = append(schemas, s.If.Schema)
+	}
+	if s.AllOf.IsSet {
+		schemas = append(schemas, s.AllOf.Schemas...)
+	}
+	if s.AnyOf.IsSet && len(s.AnyOf.Schemas) > 0 {
+		// later branches are only evaluated if the earlier ones fail
+		schemas = append(schemas, s.AnyOf.Schemas[0])
+	}
+	if s.OneOf.IsSet && len(s.OneOf.Schemas) > 0 {
+		// likewise, later branches are not evaluated once two branches have
+		// matched
+		schemas = append(schemas, s.OneOf.Schemas[0])
+	}
+
+	return schemas
+}
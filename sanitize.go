@@ -0,0 +1,150 @@
+package jsonschema
+
+import "net/url"
+
+// SanitizeMode controls which properties Sanitize removes.
+type SanitizeMode int
+
+const (
+	// SanitizeAdditional removes properties wherever a schema with
+	// "additionalProperties": false would reject them.
+	SanitizeAdditional SanitizeMode = iota
+
+	// SanitizeUndeclared removes properties wherever they are not declared in
+	// "properties" or "patternProperties", even if "additionalProperties" would
+	// allow them. Objects to which no schema with "properties",
+	// "patternProperties" or "additionalProperties" applies are left as they
+	// are.
+	SanitizeUndeclared
+)
+
+// Sanitize returns a copy of instance with unknown properties removed, much
+// like the removeAdditional option of Ajv. This is useful for stripping unknown
+// fields from data before storing it, instead of rejecting the data outright.
+//
+// Which properties are removed depends on mode. A property counts as declared
+// if "properties" or "patternProperties" of any schema which applies to its
+// object declares it. With SanitizeAdditional, a property is removed if any
+// such schema has "additionalProperties": false and does not declare it.
+//
+// Sanitization follows "$ref", "allOf", "properties", "patternProperties",
+// "additionalProperties", "items" and "additionalItems", as CoerceAndValidate
+// does. Properties are removed before the properties which remain are
+// sanitized in turn.
+//
+// The given instance is not modified. The sanitized instance is not validated;
+// call Validate on it if needed.
+func (v *Validator) Sanitize(instance interface{}, mode SanitizeMode) (interface{}, error) {
+	return v.SanitizeURI(url.URL{}, instance, mode)
+}
+
+// SanitizeURI is like Sanitize, but uses the schema identified by the given
+// URI.
+//
+// See Sanitize for how instances are sanitized, and ValidateURI for how uri
+// will be used.
+func (v *Validator) SanitizeURI(uri url.URL, instance interface{}, mode SanitizeMode) (interface{}, error) {
+	schema, ok := v.registry.Get(uri)
+	if !ok {
+		return nil, ErrNoSuchSchema
+	}
+
+	s := sanitizer{
+		walker: walker{registry: v.registry, maxStackDepth: v.config.MaxStackDepth},
+		mode:   mode,
+	}
+
+	schemas, err := s.walker.Expand(nil, schema, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Sanitize(schemas, instance)
+}
+
+// sanitizer removes unknown properties from instances.
+type sanitizer struct {
+	walker walker
+	mode   SanitizeMode
+}
+
+// Sanitize removes the properties of instance, and of its elements and members,
+// which the given schemas do not know of.
+func (s *sanitizer) Sanitize(schemas []appliedSchema, instance interface{}) (interface{}, error) {
+	switch val := instance.(type) {
+	case []interface{}:
+		if !hasItemSchemas(schemas) {
+			return val, nil
+		}
+
+		elems := make([]interface{}, len(val))
+		for i, elem := range val {
+			itemSchemas, err := s.walker.ItemSchemas(schemas, i)
+			if err != nil {
+				return nil, err
+			}
+
+			elems[i], err = s.Sanitize(itemSchemas, elem)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return elems, nil
+	case map[string]interface{}:
+		if !hasPropertySchemas(schemas) {
+			return val, nil
+		}
+
+		object := make(map[string]interface{}, len(val))
+		for _, key := range sortedKeys(val) {
+			if s.remove(schemas, key) {
+				continue
+			}
+
+			propertySchemas, err := s.walker.PropertySchemas(schemas, key)
+			if err != nil {
+				return nil, err
+			}
+
+			object[key], err = s.Sanitize(propertySchemas, val[key])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		return object, nil
+	default:
+		return val, nil
+	}
+}
+
+// remove checks whether the member with the given key should be removed from
+// an object to which schemas apply.
+func (s *sanitizer) remove(schemas []appliedSchema, key string) bool {
+	declared := false
+	rejected := false
+
+	for _, applied := range schemas {
+		applied.schema.propertySchemas(key, func(keyword, name string, index int) error {
+			if keyword != "additionalProperties" {
+				declared = true
+				return nil
+			}
+
+			additional := s.walker.registry.GetIndex(index)
+			if additional.Bool.IsSet && !additional.Bool.Value {
+				rejected = true
+			}
+
+			return nil
+		})
+	}
+
+	switch s.mode {
+	case SanitizeUndeclared:
+		return !declared
+	default:
+		return rejected
+	}
+}
@@ -0,0 +1,167 @@
+package jsonschema
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidatorSanitize(t *testing.T) {
+	testCases := []struct {
+		name      string
+		schema    interface{}
+		mode      SanitizeMode
+		instance  interface{}
+		sanitized interface{}
+	}{
+		{
+			"additional properties false",
+			map[string]interface{}{
+				"properties":           map[string]interface{}{"a": true},
+				"patternProperties":    map[string]interface{}{"^x-": true},
+				"additionalProperties": false,
+			},
+			SanitizeAdditional,
+			map[string]interface{}{"a": 1.0, "b": 2.0, "x-c": 3.0},
+			map[string]interface{}{"a": 1.0, "x-c": 3.0},
+		},
+		{
+			"additional properties allowed",
+			map[string]interface{}{
+				"properties": map[string]interface{}{"a": true},
+			},
+			SanitizeAdditional,
+			map[string]interface{}{"a": 1.0, "b": 2.0},
+			map[string]interface{}{"a": 1.0, "b": 2.0},
+		},
+		{
+			"undeclared",
+			map[string]interface{}{
+				"properties":           map[string]interface{}{"a": true},
+				"additionalProperties": map[string]interface{}{"type": "number"},
+			},
+			SanitizeUndeclared,
+			map[string]interface{}{"a": 1.0, "b": 2.0},
+			map[string]interface{}{"a": 1.0},
+		},
+		{
+			"undeclared without property keywords",
+			map[string]interface{}{"type": "object"},
+			SanitizeUndeclared,
+			map[string]interface{}{"a": 1.0},
+			map[string]interface{}{"a": 1.0},
+		},
+		{
+			"nested properties and items",
+			map[string]interface{}{
+				"properties": map[string]interface{}{
+					"a": map[string]interface{}{
+						"items": map[string]interface{}{
+							"properties":           map[string]interface{}{"b": true},
+							"additionalProperties": false,
+						},
+					},
+				},
+			},
+			SanitizeAdditional,
+			map[string]interface{}{
+				"a": []interface{}{map[string]interface{}{"b": 1.0, "c": 2.0}},
+				"d": map[string]interface{}{"e": 3.0},
+			},
+			map[string]interface{}{
+				"a": []interface{}{map[string]interface{}{"b": 1.0}},
+				"d": map[string]interface{}{"e": 3.0},
+			},
+		},
+		{
+			"ref",
+			map[string]interface{}{
+				"definitions": map[string]interface{}{
+					"a": map[string]interface{}{
+						"properties":           map[string]interface{}{"b": true},
+						"additionalProperties": false,
+					},
+				},
+				"properties": map[string]interface{}{
+					"a": map[string]interface{}{"$ref": "#/definitions/a"},
+				},
+			},
+			SanitizeAdditional,
+			map[string]interface{}{"a": map[string]interface{}{"b": 1.0, "c": 2.0}},
+			map[string]interface{}{"a": map[string]interface{}{"b": 1.0}},
+		},
+		{
+			"all of declares",
+			map[string]interface{}{
+				"allOf": []interface{}{
+					map[string]interface{}{"properties": map[string]interface{}{"a": true}},
+					map[string]interface{}{"properties": map[string]interface{}{"b": true}},
+				},
+			},
+			SanitizeUndeclared,
+			map[string]interface{}{"a": 1.0, "b": 2.0, "c": 3.0},
+			map[string]interface{}{"a": 1.0, "b": 2.0},
+		},
+		{
+			"all of rejects",
+			map[string]interface{}{
+				"allOf": []interface{}{
+					map[string]interface{}{
+						"properties":           map[string]interface{}{"a": true},
+						"additionalProperties": false,
+					},
+					map[string]interface{}{"properties": map[string]interface{}{"b": true}},
+				},
+			},
+			SanitizeAdditional,
+			map[string]interface{}{"a": 1.0, "b": 2.0},
+			map[string]interface{}{"a": 1.0},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			validator, err := NewValidator([]interface{}{tt.schema})
+			assert.NoError(t, err)
+
+			sanitized, err := validator.Sanitize(tt.instance, tt.mode)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.sanitized, sanitized)
+		})
+	}
+}
+
+func TestValidatorSanitizeCopies(t *testing.T) {
+	validator, err := NewValidator([]interface{}{
+		map[string]interface{}{"additionalProperties": false},
+	})
+	assert.NoError(t, err)
+
+	instance := map[string]interface{}{"a": 1.0}
+	sanitized, err := validator.Sanitize(instance, SanitizeAdditional)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{}, sanitized)
+	assert.Equal(t, map[string]interface{}{"a": 1.0}, instance)
+}
+
+func TestValidatorSanitizeErrors(t *testing.T) {
+	validator, err := NewValidatorWithConfig([]interface{}{
+		map[string]interface{}{
+			"definitions": map[string]interface{}{
+				"a": map[string]interface{}{"$ref": "#/definitions/b"},
+				"b": map[string]interface{}{"$ref": "#/definitions/c"},
+				"c": map[string]interface{}{"$ref": "#/definitions/d"},
+				"d": map[string]interface{}{},
+			},
+			"$ref": "#/definitions/a",
+		},
+	}, ValidatorConfig{MaxStackDepth: 3})
+	assert.NoError(t, err)
+
+	_, err = validator.Sanitize(nil, SanitizeAdditional)
+	assert.Equal(t, ErrStackOverflow, err)
+
+	_, err = validator.SanitizeURI(url.URL{Host: "example.com"}, nil, SanitizeAdditional)
+	assert.Equal(t, ErrNoSuchSchema, err)
+}
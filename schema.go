@@ -1,6 +1,7 @@
 package jsonschema
 
 import (
+	"math/big"
 	"net/url"
 	"regexp"
 
@@ -120,11 +121,55 @@ type schemaConst struct {
 type schemaEnum struct {
 	IsSet  bool
 	Values []interface{}
+
+	// Hashes indexes Values by hashJSON, or is nil if there are too few values
+	// for hashing to be worthwhile.
+	Hashes map[uint64][]int
+}
+
+// smallEnum is the number of values up to which "enum" is checked by comparing
+// the instance to each value, which for short enums is faster than hashing it.
+const smallEnum = 8
+
+func newSchemaEnum(values []interface{}) schemaEnum {
+	e := schemaEnum{IsSet: true, Values: values}
+	if len(values) <= smallEnum {
+		return e
+	}
+
+	e.Hashes = make(map[uint64][]int, len(values))
+	for i, value := range values {
+		h := hashJSON(value)
+		e.Hashes[h] = append(e.Hashes[h], i)
+	}
+
+	return e
+}
+
+func (e schemaEnum) contains(instance interface{}) bool {
+	if e.Hashes == nil {
+		for _, value := range e.Values {
+			if equalJSON(instance, value) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	for _, i := range e.Hashes[hashJSON(instance)] {
+		if equalJSON(instance, e.Values[i]) {
+			return true
+		}
+	}
+
+	return false
 }
 
 type schemaMultipleOf struct {
-	IsSet bool
-	Value float64
+	IsSet   bool
+	Value   float64
+	Decimal *big.Rat
 }
 
 type schemaMaximum struct {
@@ -209,7 +254,12 @@ type schemaProperties struct {
 
 type schemaPatternProperties struct {
 	IsSet   bool
-	Schemas map[*regexp.Regexp]int
+	Schemas []schemaPatternProperty
+}
+
+type schemaPatternProperty struct {
+	Pattern *regexp.Regexp
+	Schema  int
 }
 
 type schemaAdditionalProperties struct {
@@ -217,12 +267,54 @@ type schemaAdditionalProperties struct {
 	Schema int
 }
 
+// propertySchemas calls fn for each subschema of s which applies to the member
+// of an object with the given key. These are, in order: the subschema for key
+// in "properties", the subschemas in "patternProperties" whose pattern matches
+// key, and the subschema in "additionalProperties" if none of the others
+// applied.
+//
+// fn is given the keyword the subschema belongs to, the name of the subschema
+// within that keyword (the key or the pattern), and the subschema's index in
+// the arena. For "additionalProperties", name is always empty.
+//
+// If fn returns an error, then propertySchemas stops and returns that error.
+func (s schema) propertySchemas(key string, fn func(keyword, name string, index int) error) error {
+	isAdditional := true
+
+	if s.Properties.IsSet {
+		if index, ok := s.Properties.Schemas[key]; ok {
+			isAdditional = false
+			if err := fn("properties", key, index); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.PatternProperties.IsSet {
+		for _, patternProperty := range s.PatternProperties.Schemas {
+			if patternProperty.Pattern.MatchString(key) {
+				isAdditional = false
+				if err := fn("patternProperties", patternProperty.Pattern.String(), patternProperty.Schema); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if s.AdditionalProperties.IsSet && isAdditional {
+		return fn("additionalProperties", "", s.AdditionalProperties.Schema)
+	}
+
+	return nil
+}
+
 type schemaDependencies struct {
 	IsSet bool
-	Deps  map[string]schemaDependency
+	Deps  []schemaDependency
 }
 
 type schemaDependency struct {
+	Property   string
 	IsSchema   bool
 	Schema     int
 	Properties []string
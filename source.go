@@ -0,0 +1,235 @@
+package jsonschema
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+
+	jsonpointer "github.com/json-schema-spec/json-pointer-go"
+)
+
+// Position is a location within a source document. Offsets are counted in
+// bytes from zero. Lines and columns are counted from one, and columns are
+// counted in characters rather than bytes.
+//
+// The zero value of Position indicates that the location is not known.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// IsValid checks whether the position refers to a known location.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String fulfills the fmt.Stringer interface.
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Document is a value in the data model produced by encoding/json, together
+// with where each value within it appeared in its source.
+//
+// Documents are produced by ParseJSON and ParseYAML. Validating a Document
+// rather than a plain value lets errors report where in the source the
+// rejected part of an instance, or the rejecting part of a schema, can be
+// found.
+type Document struct {
+	// Value is the parsed document.
+	Value interface{}
+
+	sourceMap sourceMap
+}
+
+// Span returns the positions where the value at ptr begins and ends in the
+// document's source. The end position is just past the end of the value. If
+// the end of the value is not known, as for YAML documents, then end is the
+// zero Position.
+//
+// If there is no value at ptr, then ok is false.
+func (d Document) Span(ptr jsonpointer.Ptr) (start, end Position, ok bool) {
+	node, ok := d.sourceMap.lookup(ptr.Tokens)
+	if !ok {
+		return Position{}, Position{}, false
+	}
+
+	return node.start, node.end, true
+}
+
+// sourceMap records where each value in a document appeared in its source. It
+// is a tree of sourceNodes parallel to the document's value, so that the
+// pointer to a value is only built when an error is reported against it.
+type sourceMap struct {
+	root *sourceNode
+}
+
+// sourceNode is where a value appeared in a document, along with where its
+// elements or members appeared.
+type sourceNode struct {
+	sourceSpan
+
+	// elems are the nodes of the elements of an array
+	elems []*sourceNode
+
+	// members are the nodes of the members of an object, by key
+	members map[string]*sourceNode
+}
+
+// sourceSpan is where a value appeared in a document.
+type sourceSpan struct {
+	// start and end are where the value begins and ends
+	start Position
+	end   Position
+
+	// key is where the name of the value begins, if the value is a member of
+	// an object
+	key Position
+}
+
+// lookup returns the node of the value the given JSON Pointer tokens refer to.
+func (m sourceMap) lookup(tokens []string) (*sourceNode, bool) {
+	node := m.root
+	if node == nil {
+		return nil, false
+	}
+
+	for _, token := range tokens {
+		if node.members != nil {
+			child, ok := node.members[token]
+			if !ok {
+				return nil, false
+			}
+
+			node = child
+			continue
+		}
+
+		// only the canonical form of an index refers to an element
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || i >= len(node.elems) || strconv.Itoa(i) != token {
+			return nil, false
+		}
+
+		node = node.elems[i]
+	}
+
+	return node, true
+}
+
+// span returns where the value the given JSON Pointer tokens refer to
+// appeared, or the zero sourceSpan if it is not known.
+func (m sourceMap) span(tokens []string) sourceSpan {
+	node, ok := m.lookup(tokens)
+	if !ok {
+		return sourceSpan{}
+	}
+
+	return node.sourceSpan
+}
+
+// annotateInstance sets the instance positions in result's errors.
+func (m sourceMap) annotateInstance(result *ValidationResult) {
+	for i := range result.Errors {
+		span := m.span(result.Errors[i].InstancePath.Tokens)
+		result.Errors[i].InstanceStart = span.start
+		result.Errors[i].InstanceEnd = span.end
+	}
+}
+
+// annotateSchema sets the schema position of err. The position of a keyword
+// is where its name begins, or where its value begins if it has no name.
+func (m sourceMap) annotateSchema(err *ValidationError) {
+	span := m.span(err.SchemaPath.Tokens)
+	if span.key.IsValid() {
+		err.SchemaStart = span.key
+	} else {
+		err.SchemaStart = span.start
+	}
+}
+
+// lineIndex holds the offset at which each line of a document begins.
+type lineIndex struct {
+	data   []byte
+	starts []int
+
+	// last is the position most recently returned by Position or LineColumn.
+	// Positions are mostly asked for in order, so they are counted on from
+	// last rather than from the start of the line, which for long lines would
+	// take time quadratic in their length.
+	last Position
+}
+
+func newLineIndex(data []byte) lineIndex {
+	starts := []int{0}
+	for i, b := range data {
+		if b == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+
+	return lineIndex{data: data, starts: starts}
+}
+
+// Position returns the position corresponding to a byte offset.
+func (l *lineIndex) Position(offset int) Position {
+	line := sort.Search(len(l.starts), func(i int) bool {
+		return l.starts[i] > offset
+	})
+
+	var column int
+	if l.last.Line == line && l.last.Offset <= offset {
+		column = l.last.Column + utf8.RuneCount(l.data[l.last.Offset:offset])
+	} else {
+		column = utf8.RuneCount(l.data[l.starts[line-1]:offset]) + 1
+	}
+
+	l.last = Position{Offset: offset, Line: line, Column: column}
+	return l.last
+}
+
+// LineColumn returns the position corresponding to a line and column.
+func (l *lineIndex) LineColumn(line, column int) Position {
+	if line < 1 || line > len(l.starts) {
+		return Position{}
+	}
+
+	offset, i := l.starts[line-1], 1
+	if l.last.Line == line && l.last.Column <= column {
+		offset, i = l.last.Offset, l.last.Column
+	}
+
+	for ; i < column && offset < len(l.data); i++ {
+		_, size := utf8.DecodeRune(l.data[offset:])
+		offset += size
+	}
+
+	l.last = Position{Offset: offset, Line: line, Column: column}
+	return l.last
+}
+
+// schemaSourceMaps returns the source maps of documents used as schemas, keyed
+// by the URI each schema is known by.
+func schemaSourceMaps(docs []Document) map[url.URL]sourceMap {
+	sourceMaps := map[url.URL]sourceMap{}
+	for _, doc := range docs {
+		uri := url.URL{}
+		if object, ok := doc.Value.(map[string]interface{}); ok {
+			if id, ok := object["$id"].(string); ok {
+				if parsed, err := url.Parse(id); err == nil {
+					uri = *parsed
+				}
+			}
+		}
+
+		// as with the registry, the first schema with a given URI is the one used
+		if _, ok := sourceMaps[uri]; !ok {
+			sourceMaps[uri] = doc.sourceMap
+		}
+	}
+
+	return sourceMaps
+}
@@ -0,0 +1,352 @@
+package jsonschema
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// suiteDir is where the official JSON-Schema-Test-Suite is vendored. See
+// testdata/README.md for how to update it.
+const suiteDir = "testdata/JSON-Schema-Test-Suite"
+
+// suiteDrafts are the drafts of the suite which are run.
+var suiteDrafts = []string{"draft7"}
+
+// suiteRemoteBase is the URL at which the suite expects its remotes directory
+// to be served.
+const suiteRemoteBase = "http://localhost:1234/"
+
+var updateCompliance = flag.Bool("update-compliance", false, "rewrite testdata/compliance.md and testdata/known-failures.txt from the results of TestSuite")
+
+// suiteGroup is a group of tests in the official suite's format, sharing a
+// schema.
+type suiteGroup struct {
+	Description string      `json:"description"`
+	Schema      interface{} `json:"schema"`
+	Tests       []suiteTest `json:"tests"`
+}
+
+type suiteTest struct {
+	Description string      `json:"description"`
+	Data        interface{} `json:"data"`
+	Valid       bool        `json:"valid"`
+}
+
+// suiteResult is the outcome of a single test of the suite.
+type suiteResult struct {
+	ID      string // draft/keyword/group/test
+	Draft   string
+	Keyword string
+	Passed  bool
+}
+
+func TestSuite(t *testing.T) {
+	if _, err := os.Stat(filepath.Join(suiteDir, "tests")); os.IsNotExist(err) {
+		t.Skipf("%s is not present; see testdata/README.md", suiteDir)
+	}
+
+	results, err := runSuite(suiteDir, suiteDrafts)
+	assert.NoError(t, err)
+
+	if *updateCompliance {
+		err := ioutil.WriteFile("testdata/compliance.md", []byte(complianceReport(results)), 0644)
+		assert.NoError(t, err)
+
+		err = ioutil.WriteFile("testdata/known-failures.txt", []byte(knownFailuresFile(results)), 0644)
+		assert.NoError(t, err)
+		return
+	}
+
+	knownFailures, err := readKnownFailures("testdata/known-failures.txt")
+	assert.NoError(t, err)
+
+	for _, result := range results {
+		switch {
+		case !result.Passed && !knownFailures[result.ID]:
+			t.Errorf("failed: %s", result.ID)
+		case result.Passed && knownFailures[result.ID]:
+			t.Errorf("passed, but is listed in testdata/known-failures.txt: %s", result.ID)
+		}
+	}
+
+	report, err := ioutil.ReadFile("testdata/compliance.md")
+	assert.NoError(t, err)
+	assert.Equal(t, string(report), complianceReport(results), "testdata/compliance.md is out of date; run go test -run TestSuite -update-compliance")
+}
+
+func TestRunSuite(t *testing.T) {
+	results, err := runSuite("testdata/suite-fixture", []string{"draft7"})
+	assert.NoError(t, err)
+
+	passed := map[string]bool{}
+	for _, result := range results {
+		passed[result.ID] = result.Passed
+	}
+
+	assert.Equal(t, map[string]bool{
+		"draft7/ref/remote ref/remote ref valid":                         true,
+		"draft7/ref/remote ref/remote ref invalid":                       true,
+		"draft7/ref/remote ref without id/nested remote ref valid":       true,
+		"draft7/ref/remote ref without id/nested remote ref invalid":     true,
+		"draft7/ref/unresolvable ref/any value":                          false,
+		"draft7/type/integer type matches integers/an integer is valid":  true,
+		"draft7/type/integer type matches integers/a string is invalid":  true,
+		"draft7/type/integer type matches integers/a float is not valid": true,
+		"draft7/optional/format/date/valid date/a valid date":            true,
+		"draft7/optional/format/date/valid date/non-strings are ignored": true,
+	}, passed)
+
+	assert.Equal(t, `# JSON-Schema-Test-Suite compliance
+
+This file is generated by `+"`go test -run TestSuite -update-compliance`"+`.
+
+| Draft | Keyword | Passed | Failed |
+|---|---|---|---|
+| draft7 | optional/format/date | 2 | 0 |
+| draft7 | ref | 4 | 1 |
+| draft7 | type | 3 | 0 |
+| draft7 | **total** | 9 | 1 |
+`, complianceReport(results))
+
+	assert.Equal(t, `# Tests of JSON-Schema-Test-Suite which are known to fail. Each line is
+# draft/keyword/group/test. This file is generated by
+# go test -run TestSuite -update-compliance.
+draft7/ref/unresolvable ref/any value
+`, knownFailuresFile(results))
+}
+
+// runSuite runs the tests of the given drafts of a copy of the official suite
+// in dir.
+func runSuite(dir string, drafts []string) ([]suiteResult, error) {
+	remotes, err := loadSuiteRemotes(filepath.Join(dir, "remotes"))
+	if err != nil {
+		return nil, err
+	}
+
+	results := []suiteResult{}
+	for _, draft := range drafts {
+		draftDir := filepath.Join(dir, "tests", draft)
+		err := filepath.Walk(draftDir, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if info.IsDir() || filepath.Ext(file) != ".json" {
+				return nil
+			}
+
+			rel, err := filepath.Rel(draftDir, file)
+			if err != nil {
+				return err
+			}
+
+			keyword := strings.TrimSuffix(filepath.ToSlash(rel), ".json")
+			fileResults, err := runSuiteFile(file, draft, keyword, remotes)
+			if err != nil {
+				return err
+			}
+
+			results = append(results, fileResults...)
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+func runSuiteFile(file, draft, keyword string, remotes []interface{}) ([]suiteResult, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []suiteGroup
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return nil, fmt.Errorf("%s: %v", file, err)
+	}
+
+	results := []suiteResult{}
+	for _, group := range groups {
+		schemas := append([]interface{}{group.Schema}, remotes...)
+		validator, err := NewValidator(schemas)
+
+		for _, test := range group.Tests {
+			passed := false
+			if err == nil {
+				result, err := validator.Validate(test.Data)
+				passed = err == nil && result.IsValid() == test.Valid
+			}
+
+			results = append(results, suiteResult{
+				ID:      path.Join(draft, keyword, group.Description, test.Description),
+				Draft:   draft,
+				Keyword: keyword,
+				Passed:  passed,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// loadSuiteRemotes loads the schemas in the suite's remotes directory, as if
+// they were served from suiteRemoteBase. Remotes without an "$id" are given
+// their URL as one.
+func loadSuiteRemotes(dir string) ([]interface{}, error) {
+	remotes := []interface{}{}
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || filepath.Ext(file) != ".json" {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		var remote interface{}
+		if err := json.Unmarshal(data, &remote); err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+
+		if object, ok := remote.(map[string]interface{}); ok {
+			if _, ok := object["$id"]; !ok {
+				object["$id"] = suiteRemoteBase + filepath.ToSlash(rel)
+			}
+		}
+
+		remotes = append(remotes, remote)
+		return nil
+	})
+
+	if os.IsNotExist(err) {
+		return remotes, nil
+	}
+
+	return remotes, err
+}
+
+// complianceReport summarizes results by draft and keyword, as markdown.
+func complianceReport(results []suiteResult) string {
+	type count struct{ passed, failed int }
+
+	counts := map[string]map[string]*count{}
+	drafts := []string{}
+	for _, result := range results {
+		if _, ok := counts[result.Draft]; !ok {
+			counts[result.Draft] = map[string]*count{}
+			drafts = append(drafts, result.Draft)
+		}
+
+		c, ok := counts[result.Draft][result.Keyword]
+		if !ok {
+			c = &count{}
+			counts[result.Draft][result.Keyword] = c
+		}
+
+		if result.Passed {
+			c.passed++
+		} else {
+			c.failed++
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("# JSON-Schema-Test-Suite compliance\n\n")
+	b.WriteString("This file is generated by `go test -run TestSuite -update-compliance`.\n\n")
+	b.WriteString("| Draft | Keyword | Passed | Failed |\n")
+	b.WriteString("|---|---|---|---|\n")
+
+	sort.Strings(drafts)
+	for _, draft := range drafts {
+		keywords := []string{}
+		for keyword := range counts[draft] {
+			keywords = append(keywords, keyword)
+		}
+
+		sort.Strings(keywords)
+
+		total := count{}
+		for _, keyword := range keywords {
+			c := counts[draft][keyword]
+			total.passed += c.passed
+			total.failed += c.failed
+			fmt.Fprintf(&b, "| %s | %s | %d | %d |\n", draft, keyword, c.passed, c.failed)
+		}
+
+		fmt.Fprintf(&b, "| %s | **total** | %d | %d |\n", draft, total.passed, total.failed)
+	}
+
+	return b.String()
+}
+
+// knownFailuresFile lists the IDs of the failed results, in the format read by
+// readKnownFailures.
+func knownFailuresFile(results []suiteResult) string {
+	var b strings.Builder
+	b.WriteString("# Tests of JSON-Schema-Test-Suite which are known to fail. Each line is\n")
+	b.WriteString("# draft/keyword/group/test. This file is generated by\n")
+	b.WriteString("# go test -run TestSuite -update-compliance.\n")
+
+	ids := []string{}
+	for _, result := range results {
+		if !result.Passed {
+			ids = append(ids, result.ID)
+		}
+	}
+
+	sort.Strings(ids)
+	for _, id := range ids {
+		b.WriteString(id + "\n")
+	}
+
+	return b.String()
+}
+
+// readKnownFailures reads the IDs of tests which are expected to fail. Blank
+// lines and lines starting with "#" are ignored.
+func readKnownFailures(file string) (map[string]bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	ids := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		ids[line] = true
+	}
+
+	return ids, scanner.Err()
+}
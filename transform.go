@@ -0,0 +1,129 @@
+package jsonschema
+
+// walker finds which schemas apply to each part of an instance, for operations
+// which transform instances rather than validate them, such as coercion and
+// sanitization.
+//
+// Only subschemas which apply unconditionally are found: those of "$ref",
+// "allOf", "properties", "patternProperties", "additionalProperties", "items"
+// and "additionalItems". Whether subschemas of keywords like "anyOf" or "if"
+// apply depends on the instance, which transformations are in the middle of
+// changing, so they are not used.
+type walker struct {
+	registry      registry
+	maxStackDepth int
+}
+
+// appliedSchema is a schema which applies to part of an instance.
+type appliedSchema struct {
+	schema schema
+}
+
+// Expand appends s, and the schemas which apply through it with "$ref" and
+// "allOf", to schemas. depth is how many "$ref"-s have been followed to reach s
+// without descending into the instance.
+func (w walker) Expand(schemas []appliedSchema, s schema, depth int) ([]appliedSchema, error) {
+	schemas = append(schemas, appliedSchema{schema: s})
+	if s.Bool.IsSet {
+		return schemas, nil
+	}
+
+	if s.Ref.IsSet {
+		if depth == w.maxStackDepth {
+			return nil, ErrStackOverflow
+		}
+
+		var err error
+		schemas, err = w.Expand(schemas, w.registry.GetIndex(s.Ref.Schema), depth+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if s.AllOf.IsSet {
+		for _, index := range s.AllOf.Schemas {
+			var err error
+			schemas, err = w.Expand(schemas, w.registry.GetIndex(index), depth)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return schemas, nil
+}
+
+// PropertySchemas returns the schemas which apply to the member with the given
+// key of an object to which schemas apply.
+func (w walker) PropertySchemas(schemas []appliedSchema, key string) ([]appliedSchema, error) {
+	children := []appliedSchema{}
+	for _, s := range schemas {
+		err := s.schema.propertySchemas(key, func(keyword, name string, index int) error {
+			var err error
+			children, err = w.Expand(children, w.registry.GetIndex(index), 0)
+			return err
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return children, nil
+}
+
+// ItemSchemas returns the schemas which apply to the element at index i of an
+// array to which schemas apply.
+func (w walker) ItemSchemas(schemas []appliedSchema, i int) ([]appliedSchema, error) {
+	children := []appliedSchema{}
+	for _, s := range schemas {
+		items := s.schema.Items
+		if !items.IsSet {
+			continue
+		}
+
+		var index int
+		switch {
+		case items.IsSingle:
+			index = items.Schemas[0]
+		case i < len(items.Schemas):
+			index = items.Schemas[i]
+		case s.schema.AdditionalItems.IsSet:
+			index = s.schema.AdditionalItems.Schema
+		default:
+			continue
+		}
+
+		var err error
+		children, err = w.Expand(children, w.registry.GetIndex(index), 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return children, nil
+}
+
+// hasPropertySchemas checks whether any of schemas have subschemas for the
+// members of objects.
+func hasPropertySchemas(schemas []appliedSchema) bool {
+	for _, s := range schemas {
+		if s.schema.Properties.IsSet || s.schema.PatternProperties.IsSet || s.schema.AdditionalProperties.IsSet {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasItemSchemas checks whether any of schemas have subschemas for the
+// elements of arrays.
+func hasItemSchemas(schemas []appliedSchema) bool {
+	for _, s := range schemas {
+		if s.schema.Items.IsSet {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,7 +1,10 @@
 package jsonschema
 
 import (
+	"context"
 	"net/url"
+	"runtime"
+	"sync"
 
 	jsonpointer "github.com/json-schema-spec/json-pointer-go"
 )
@@ -10,17 +13,50 @@ import (
 // ValidatorConfig.
 const DefaultMaxStackDepth = 128
 
+// DefaultMaxInstanceDepth is the default value for MaxInstanceDepth in
+// ValidatorConfig.
+const DefaultMaxInstanceDepth = 1000
+
+// DefaultEpsilon is the default value for Epsilon in ValidatorConfig.
+const DefaultEpsilon = 1e-3
+
+// Epsilon is the tolerance used for "multipleOf" by Validators whose
+// ValidatorConfig leaves Epsilon as zero. It is read when each Validator is
+// constructed.
+//
+// Deprecated: Set Epsilon in ValidatorConfig instead. It is not safe to modify
+// Epsilon while any Validator may be under construction.
+var Epsilon = DefaultEpsilon
+
 // Validator compiles schemas and evaluates instances.
+//
+// A Validator is safe for concurrent use by multiple goroutines. Once
+// constructed, its compiled schemas are never modified, and each evaluation of
+// an instance uses its own private state. Copies of a Validator share their
+// compiled schemas, and are equally safe to use concurrently.
 type Validator struct {
-	registry      registry
-	maxStackDepth int
-	maxErrors     int
+	registry registry
+	program  *program
+	config   ValidatorConfig
+
+	// sourceMaps holds where the parts of each schema appeared in its source,
+	// for schemas constructed from Documents.
+	sourceMaps map[url.URL]sourceMap
+
+	// pool holds vms which are not in use, so that the storage they have
+	// allocated may be reused by later evaluations.
+	pool *sync.Pool
 }
 
 // ValidatorConfig contains configuration for a Validator.
 type ValidatorConfig struct {
 	// MaxStackDepth is the maximum number of cross-references a Validator will
-	// follow before returning ErrStackOverflow.
+	// follow without descending into the instance before returning
+	// ErrStackOverflow.
+	//
+	// Cross-references followed at different depths within the instance are
+	// not counted together, so recursive schemas can validate deeply nested
+	// instances. MaxInstanceDepth bounds that kind of recursion instead.
 	MaxStackDepth int
 
 	// MaxErrors is the maximum number of errors to return before the Validator
@@ -28,13 +64,141 @@ type ValidatorConfig struct {
 	//
 	// A value of zero indicates to produce all errors.
 	MaxErrors int
+
+	// CountAllErrors indicates that, once MaxErrors errors have been produced,
+	// the Validator should continue evaluation in order to count how many
+	// errors there are in total. Errors past the first MaxErrors are counted,
+	// but not returned.
+	//
+	// This option has no effect if MaxErrors is zero.
+	CountAllErrors bool
+
+	// Epsilon is the value used to determine if a floating-point value is
+	// "close enough" to be considered a multiple of the value of "multipleOf".
+	// It is relative to that value when that value is less than one: with the
+	// default of 0.001 and "multipleOf": 0.01, a number is accepted if it is
+	// within 0.00001 of a multiple of 0.01. For larger values of "multipleOf",
+	// a number must be within Epsilon itself of a multiple.
+	//
+	// Floating-point arithmetic cannot represent most decimal fractions
+	// exactly, so some tolerance is needed for "multipleOf" to give the results
+	// you would expect. See also ExactDecimal, which avoids this problem
+	// altogether.
+	//
+	// All other comparisons between numbers, including those for "minimum",
+	// "maximum", "exclusiveMinimum" and "exclusiveMaximum", are exact.
+	//
+	// A value of zero indicates the default, which is the value of the
+	// deprecated package-level Epsilon, DefaultEpsilon unless it has been
+	// changed. A negative value indicates that no tolerance is allowed.
+	Epsilon float64
+
+	// IntegerEpsilon is the value used to determine if a floating-point value
+	// is "close enough" to be considered an integer. It is used for the
+	// "integer" type, and for keywords whose values must be non-negative
+	// integers, such as "maxLength".
+	//
+	// You may adjust this value if numbers in your instances or schemas pass
+	// through calculations that may introduce small errors, but consider that
+	// floating-point arithmetic has different precision based on the range of
+	// numbers being represented.
+	//
+	// A value of zero indicates that only exact integers are considered to be
+	// integers.
+	IntegerEpsilon float64
+
+	// ExactDecimal indicates that "multipleOf" should be evaluated using exact
+	// decimal arithmetic on the shortest decimal representation of each number,
+	// rather than floating-point arithmetic. With this option, 19.99 is a
+	// multiple of 0.01, regardless of Epsilon.
+	ExactDecimal bool
+
+	// DeduplicateErrors indicates that an error with the same InstancePath,
+	// SchemaPath, and URI as an error already produced should be discarded.
+	// Such duplicates can arise when the same subschema is reached more than
+	// once, for instance through several "$ref"s.
+	DeduplicateErrors bool
+
+	// Memoize indicates that, within a single evaluation, the Validator should
+	// remember whether each subschema evaluated under "not", "if", "anyOf",
+	// "oneOf" or "contains" accepted each object or array it was evaluated
+	// against, and reuse that outcome rather than evaluating it again. Objects
+	// and arrays are told apart by identity, not by value.
+	//
+	// Recursive schemas that use these keywords, such as ones describing
+	// syntax trees, can otherwise take time exponential in the depth of an
+	// instance. Memoization does not change which errors are produced, but
+	// work it saves does not count towards MaxNodes or MaxFuel.
+	Memoize bool
+
+	// MaxInstanceDepth is the maximum depth of nesting within an instance that
+	// a Validator will descend into before returning ErrBudgetExceeded. It is
+	// what bounds recursive schemas, such as {"items": {"$ref": "#"}}, which
+	// follow a cross-reference at each level of an instance.
+	//
+	// A value of zero indicates no limit.
+	MaxInstanceDepth int
+
+	// MaxNodes is the maximum number of times a Validator will visit a value
+	// within an instance before returning ErrBudgetExceeded. A value is counted
+	// each time it is visited, so values evaluated by many subschemas, such as
+	// under "anyOf" or "oneOf", are counted many times.
+	//
+	// A value of zero indicates no limit.
+	MaxNodes int
+
+	// MaxFuel is the maximum number of keywords a Validator will evaluate
+	// before returning ErrBudgetExceeded.
+	//
+	// A value of zero indicates no limit.
+	MaxFuel int
+
+	// MaxStringLength is the maximum length, in bytes, of a string within an
+	// instance. Longer strings cause the Validator to return ErrBudgetExceeded.
+	//
+	// A value of zero indicates no limit.
+	MaxStringLength int
+
+	// MaxArrayLength is the maximum number of elements in an array within an
+	// instance. Longer arrays cause the Validator to return ErrBudgetExceeded.
+	//
+	// A value of zero indicates no limit.
+	MaxArrayLength int
+
+	// SubSchemaURIs lists parts of schemas which should be available to
+	// ValidateURI, in addition to the ones referred to with "$ref". Each URI
+	// is the URI of one of the given schemas, with a JSON Pointer fragment
+	// pointing to the part of that schema to make available.
+	//
+	// This is useful when schemas are embedded in larger documents, such as
+	// API descriptions, which are not themselves schemas.
+	SubSchemaURIs []url.URL
 }
 
 // ValidationResult contains information on whether an instance successfully
 // validated, as well as any relevant validation errors.
+//
+// Errors are produced in a deterministic order. Keywords are evaluated in a
+// fixed order, and the properties of objects, both in instances and schemas,
+// are visited in lexicographic order of their names. Validating the same
+// instance against the same schemas will always produce the same errors, in
+// the same order.
 type ValidationResult struct {
-	Errors     []ValidationError
+	// Errors is the list of errors produced during validation. It contains at
+	// most MaxErrors entries.
+	Errors []ValidationError
+
+	// Overflowed is whether there were more errors than could be returned in
+	// Errors because of MaxErrors.
+	//
+	// Unless CountAllErrors is set, evaluation quits as soon as MaxErrors errors
+	// are produced, and Overflowed will be true even if that happened to be the
+	// last error in the instance.
 	Overflowed bool
+
+	// ErrorCount is the number of errors produced during validation. Unless
+	// CountAllErrors is set, this is always the length of Errors.
+	ErrorCount int
 }
 
 // IsValid checks whether the result of schema validation found the instance to
@@ -57,6 +221,23 @@ type ValidationError struct {
 
 	// The URI of the schema which rejected part of the instance.
 	URI url.URL
+
+	// The positions in the instance's source document where the rejected part
+	// of the instance begins and ends. These are only known for instances
+	// validated as a Document, and are otherwise the zero Position. The end is
+	// not known for YAML documents.
+	InstanceStart Position
+	InstanceEnd   Position
+
+	// The position in the schema's source document of the keyword which
+	// rejected part of the instance. This is only known for Validators
+	// constructed from Documents, and is otherwise the zero Position.
+	SchemaStart Position
+
+	// For errors produced by "uniqueItems", the indices of two equal elements
+	// of the rejected array, earlier first: the earliest element to be
+	// repeated, and the first element to repeat it. It is nil for other errors.
+	Duplicates []int
 }
 
 // NewValidator constructs a new Validator that will use the given schemas.
@@ -73,7 +254,8 @@ type ValidationError struct {
 // times in the list.
 func NewValidator(schemas []interface{}) (Validator, error) {
 	return NewValidatorWithConfig(schemas, ValidatorConfig{
-		MaxStackDepth: DefaultMaxStackDepth,
+		MaxStackDepth:    DefaultMaxStackDepth,
+		MaxInstanceDepth: DefaultMaxInstanceDepth,
 	})
 }
 
@@ -83,13 +265,58 @@ func NewValidator(schemas []interface{}) (Validator, error) {
 // See NewValidator for how schemas will be used. See ValidatorConfig for
 // configuration options.
 func NewValidatorWithConfig(schemas []interface{}, config ValidatorConfig) (Validator, error) {
-	v := Validator{
-		maxStackDepth: config.MaxStackDepth,
-		maxErrors:     config.MaxErrors,
+	switch {
+	case config.Epsilon == 0:
+		config.Epsilon = Epsilon
+	case config.Epsilon < 0:
+		config.Epsilon = 0
+	}
+
+	v := Validator{config: config}
+
+	if err := v.seal(schemas); err != nil {
+		return v, err
+	}
+
+	registry := v.registry
+	program := v.program
+	v.pool = &sync.Pool{
+		New: func() interface{} {
+			vm := newVM(context.Background(), registry, program, config)
+			return &vm
+		},
+	}
+
+	return v, nil
+}
+
+// NewValidatorFromDocuments is like NewValidator, but uses the values of the
+// given Documents as schemas. Errors produced by the Validator will carry the
+// position of the rejecting keyword in its Document's source in SchemaStart.
+func NewValidatorFromDocuments(schemas []Document) (Validator, error) {
+	return NewValidatorFromDocumentsWithConfig(schemas, ValidatorConfig{
+		MaxStackDepth:    DefaultMaxStackDepth,
+		MaxInstanceDepth: DefaultMaxInstanceDepth,
+	})
+}
+
+// NewValidatorFromDocumentsWithConfig is like NewValidatorFromDocuments, but
+// uses the given config.
+//
+// See NewValidatorWithConfig for how config will be used.
+func NewValidatorFromDocumentsWithConfig(schemas []Document, config ValidatorConfig) (Validator, error) {
+	values := make([]interface{}, len(schemas))
+	for i, schema := range schemas {
+		values[i] = schema.Value
+	}
+
+	v, err := NewValidatorWithConfig(values, config)
+	if err != nil {
+		return v, err
 	}
 
-	err := v.seal(schemas)
-	return v, err
+	v.sourceMaps = schemaSourceMaps(schemas)
+	return v, nil
 }
 
 func (v *Validator) seal(schemas []interface{}) error {
@@ -97,7 +324,7 @@ func (v *Validator) seal(schemas []interface{}) error {
 	rawSchemas := map[url.URL]interface{}{}
 
 	for _, schema := range schemas {
-		parsed, err := parseRootSchema(&registry, schema)
+		parsed, err := parseRootSchema(&registry, v.config.IntegerEpsilon, schema)
 		if err != nil {
 			return err
 		}
@@ -108,6 +335,12 @@ func (v *Validator) seal(schemas []interface{}) error {
 	missingURIs := registry.PopulateRefs() // uris which must be accounted for
 	undefinedURIs := []url.URL{}           // uris which cannot be accounted for
 
+	for _, uri := range v.config.SubSchemaURIs {
+		if _, ok := registry.schemas[uri]; !ok {
+			missingURIs = append(missingURIs, uri)
+		}
+	}
+
 	for len(missingURIs) > 0 && len(undefinedURIs) == 0 {
 		for _, uri := range missingURIs {
 			baseURI := uri
@@ -124,7 +357,7 @@ func (v *Validator) seal(schemas []interface{}) error {
 					return err
 				}
 
-				_, err = parseSubSchema(&registry, baseURI, ptr.Tokens, *rawRefSchema)
+				_, err = parseSubSchema(&registry, v.config.IntegerEpsilon, baseURI, ptr.Tokens, *rawRefSchema)
 				if err != nil {
 					return err
 				}
@@ -140,7 +373,12 @@ func (v *Validator) seal(schemas []interface{}) error {
 		return ErrMissingURIs{URIs: undefinedURIs}
 	}
 
+	if cycle := registry.FindRefCycle(); cycle != nil {
+		return ErrRefCycle{URIs: cycle}
+	}
+
 	v.registry = registry
+	v.program = compileProgram(v.registry.arena.schemas)
 	return nil
 }
 
@@ -149,7 +387,15 @@ func (v *Validator) seal(schemas []interface{}) error {
 //
 // If no default schema exists for the validator, ErrNoSuchSchema is returned.
 func (v *Validator) Validate(instance interface{}) (ValidationResult, error) {
-	return v.ValidateURI(url.URL{}, instance)
+	return v.ValidateURIContext(context.Background(), url.URL{}, instance)
+}
+
+// ValidateContext is like Validate, but abandons evaluation once ctx is done.
+//
+// If ctx is cancelled or its deadline passes before evaluation completes, the
+// error from ctx.Err() is returned.
+func (v *Validator) ValidateContext(ctx context.Context, instance interface{}) (ValidationResult, error) {
+	return v.ValidateURIContext(ctx, url.URL{}, instance)
 }
 
 // ValidateURI evaluates the given instance against the schema identified by the
@@ -158,12 +404,196 @@ func (v *Validator) Validate(instance interface{}) (ValidationResult, error) {
 // If no schema with the given URI exists for the validator, ErrNoSuchSchema is
 // returned.
 func (v *Validator) ValidateURI(uri url.URL, instance interface{}) (ValidationResult, error) {
-	vm := newVM(v.registry, v.maxStackDepth, v.maxErrors)
+	return v.ValidateURIContext(context.Background(), uri, instance)
+}
+
+// ValidateURIContext is like ValidateURI, but abandons evaluation once ctx is
+// done.
+//
+// The context is checked periodically during evaluation, not continuously, so
+// a small amount of work may still be performed after ctx is done. If ctx is
+// cancelled or its deadline passes before evaluation completes, the error from
+// ctx.Err() is returned.
+func (v *Validator) ValidateURIContext(ctx context.Context, uri url.URL, instance interface{}) (ValidationResult, error) {
+	vm := v.getVM(ctx)
+	defer v.putVM(vm)
+
+	return v.exec(vm, uri, instance)
+}
+
+// IsValid reports whether the given instance is valid against the default
+// schema of the Validator.
+//
+// Evaluation stops at the first error, and none of the work of describing
+// errors is done, so IsValid is faster than Validate when only a yes or no
+// answer is needed. MaxErrors, CountAllErrors and DeduplicateErrors have no
+// effect on it. All other limits are enforced as with Validate, though
+// evaluation may stop before reaching them.
+func (v *Validator) IsValid(instance interface{}) (bool, error) {
+	return v.IsValidURIContext(context.Background(), url.URL{}, instance)
+}
+
+// IsValidContext is like IsValid, but abandons evaluation once ctx is done.
+func (v *Validator) IsValidContext(ctx context.Context, instance interface{}) (bool, error) {
+	return v.IsValidURIContext(ctx, url.URL{}, instance)
+}
+
+// IsValidURI is like IsValid, but evaluates the instance against the schema
+// identified by the given URI.
+//
+// See ValidateURI for how uri will be used.
+func (v *Validator) IsValidURI(uri url.URL, instance interface{}) (bool, error) {
+	return v.IsValidURIContext(context.Background(), uri, instance)
+}
+
+// IsValidURIContext is like IsValidURI, but abandons evaluation once ctx is
+// done.
+//
+// See ValidateURIContext for how ctx will be used.
+func (v *Validator) IsValidURIContext(ctx context.Context, uri url.URL, instance interface{}) (bool, error) {
+	vm := v.getVM(ctx)
+	defer v.putVM(vm)
+
+	return vm.IsValid(uri, instance)
+}
 
+// exec evaluates the instance with a vm that is ready to do so.
+func (v *Validator) exec(vm *vm, uri url.URL, instance interface{}) (ValidationResult, error) {
 	err := vm.Exec(uri, instance)
 	if err != nil {
 		return ValidationResult{}, err
 	}
 
-	return vm.ValidationResult(), nil
+	result := vm.ValidationResult()
+	v.annotateSchema(&result)
+	return result, nil
+}
+
+// ValidateDocument is like Validate, but evaluates the value of a Document.
+// Errors in the result carry the position of the rejected part of the
+// instance in InstanceStart and InstanceEnd.
+func (v *Validator) ValidateDocument(doc Document) (ValidationResult, error) {
+	return v.ValidateURIDocument(url.URL{}, doc)
+}
+
+// ValidateURIDocument is like ValidateURI, but evaluates the value of a
+// Document.
+//
+// See ValidateDocument for how positions are reported.
+func (v *Validator) ValidateURIDocument(uri url.URL, doc Document) (ValidationResult, error) {
+	result, err := v.ValidateURI(uri, doc.Value)
+	if err != nil {
+		return result, err
+	}
+
+	doc.sourceMap.annotateInstance(&result)
+	return result, nil
+}
+
+// ValidateBatch evaluates each of the given instances against the default
+// schema of the Validator, spreading the work across up to the given number of
+// goroutines. If workers is zero or negative, runtime.GOMAXPROCS(0) goroutines
+// are used.
+//
+// The returned results are in the same order as instances. If evaluating any
+// instance returns an error, then the error for the earliest such instance is
+// returned, and no results are returned.
+func (v *Validator) ValidateBatch(instances []interface{}, workers int) ([]ValidationResult, error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	if workers > len(instances) {
+		workers = len(instances)
+	}
+
+	results := make([]ValidationResult, len(instances))
+	errs := make([]error, len(instances))
+	indices := make(chan int)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+
+			for index := range indices {
+				results[index], errs[index] = v.Validate(instances[index])
+			}
+		}()
+	}
+
+	for i := range instances {
+		indices <- i
+	}
+
+	close(indices)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// ResolveRef finds the schema that the "$ref" keyword of a schema refers to.
+// Schemas are identified by the URI of the schema they are part of, with a
+// JSON Pointer fragment pointing to the part, as in ValidateURI. The returned
+// URI identifies the schema referred to in the same way.
+//
+// If there is no schema with the given URI, or it has no "$ref" keyword, then
+// ok is false.
+func (v *Validator) ResolveRef(uri url.URL) (ref url.URL, ok bool) {
+	s, ok := v.registry.Get(uri)
+	if !ok || !s.Ref.IsSet {
+		return url.URL{}, false
+	}
+
+	return s.Ref.URI, true
+}
+
+// annotateSchema sets the schema positions in result's errors, if the schemas
+// came from Documents.
+func (v *Validator) annotateSchema(result *ValidationResult) {
+	if v.sourceMaps == nil {
+		return
+	}
+
+	for i := range result.Errors {
+		uri := result.Errors[i].URI
+		uri.Fragment = ""
+
+		if sourceMap, ok := v.sourceMaps[uri]; ok {
+			sourceMap.annotateSchema(&result.Errors[i])
+		}
+	}
+}
+
+// getVM returns a vm ready to evaluate an instance under the given context.
+func (v *Validator) getVM(ctx context.Context) *vm {
+	if v.pool == nil {
+		vm := newVM(ctx, v.registry, v.program, v.config)
+		return &vm
+	}
+
+	vm := v.pool.Get().(*vm)
+	vm.reset(ctx)
+	return vm
+}
+
+// putVM returns a vm obtained from getVM once it is no longer in use.
+func (v *Validator) putVM(vm *vm) {
+	if v.pool == nil {
+		return
+	}
+
+	// the result holds on to the errors, so they must not be reused
+	vm.errors = vmErrors{}
+	vm.ctx = nil
+	vm.done = nil
+	v.pool.Put(vm)
 }
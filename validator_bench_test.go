@@ -0,0 +1,406 @@
+package jsonschema
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// benchmarkSchema resembles the schemas of a typical ingestion pipeline: a
+// record with a handful of typed and constrained properties, nested objects
+// reached through "$ref", and an array of them.
+const benchmarkSchema = `{
+	"definitions": {
+		"address": {
+			"type": "object",
+			"required": ["street", "city", "country"],
+			"properties": {
+				"street": { "type": "string", "minLength": 1, "maxLength": 200 },
+				"city": { "type": "string", "minLength": 1, "maxLength": 100 },
+				"zip": { "type": "string", "pattern": "^[0-9]{5}$" },
+				"country": { "enum": ["US", "CA", "MX", "GB", "DE", "FR"] }
+			},
+			"additionalProperties": false
+		},
+		"tag": {
+			"anyOf": [
+				{ "type": "string", "maxLength": 32 },
+				{ "type": "integer", "minimum": 0 }
+			]
+		}
+	},
+	"type": "object",
+	"required": ["id", "name", "email", "addresses"],
+	"properties": {
+		"id": { "type": "integer", "minimum": 1 },
+		"name": { "type": "string", "minLength": 1, "maxLength": 100 },
+		"email": { "type": "string", "pattern": "^[^@]+@[^@]+$" },
+		"age": { "type": "integer", "minimum": 0, "maximum": 150 },
+		"score": { "type": "number", "multipleOf": 0.5 },
+		"active": { "type": "boolean" },
+		"addresses": {
+			"type": "array",
+			"minItems": 1,
+			"maxItems": 10,
+			"items": { "$ref": "#/definitions/address" }
+		},
+		"tags": {
+			"type": "array",
+			"uniqueItems": true,
+			"items": { "$ref": "#/definitions/tag" }
+		},
+		"metadata": {
+			"type": "object",
+			"propertyNames": { "pattern": "^[a-z_]+$" },
+			"additionalProperties": { "type": ["string", "number", "boolean", "null"] }
+		}
+	}
+}`
+
+const benchmarkValidInstance = `{
+	"id": 42,
+	"name": "Alice Example",
+	"email": "alice@example.com",
+	"age": 34,
+	"score": 87.5,
+	"active": true,
+	"addresses": [
+		{ "street": "1 Main St", "city": "Springfield", "zip": "12345", "country": "US" },
+		{ "street": "2 High St", "city": "Shelbyville", "country": "CA" }
+	],
+	"tags": ["a", "b", 3, "d"],
+	"metadata": { "source": "import", "batch": 7, "verified": false, "note": null }
+}`
+
+const benchmarkInvalidInstance = `{
+	"id": 0,
+	"name": "",
+	"email": "not an email",
+	"age": 200,
+	"score": 87.3,
+	"active": "yes",
+	"addresses": [
+		{ "street": "1 Main St", "city": "Springfield", "zip": "123", "country": "XX", "extra": 1 },
+		{ "city": "Shelbyville" }
+	],
+	"tags": ["a", "a", -1],
+	"metadata": { "Source": "import", "nested": {} }
+}`
+
+func BenchmarkValidatorValidate(b *testing.B) {
+	benchmarks := []struct {
+		name     string
+		instance string
+		config   ValidatorConfig
+	}{
+		{"valid", benchmarkValidInstance, ValidatorConfig{MaxStackDepth: DefaultMaxStackDepth}},
+		{"invalid", benchmarkInvalidInstance, ValidatorConfig{MaxStackDepth: DefaultMaxStackDepth}},
+		{"invalid first error", benchmarkInvalidInstance, ValidatorConfig{MaxStackDepth: DefaultMaxStackDepth, MaxErrors: 1}},
+	}
+
+	for _, bb := range benchmarks {
+		b.Run(bb.name, func(b *testing.B) {
+			validator := benchmarkValidator(b, benchmarkSchema, bb.config)
+			instance := benchmarkInstance(b, bb.instance)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := validator.Validate(instance); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkValidatorIsValid(b *testing.B) {
+	benchmarks := []struct {
+		name     string
+		instance string
+	}{
+		{"valid", benchmarkValidInstance},
+		{"invalid", benchmarkInvalidInstance},
+	}
+
+	for _, bb := range benchmarks {
+		b.Run(bb.name, func(b *testing.B) {
+			validator := benchmarkValidator(b, benchmarkSchema, ValidatorConfig{MaxStackDepth: DefaultMaxStackDepth})
+			instance := benchmarkInstance(b, bb.instance)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := validator.IsValid(instance); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkEvaluatorValidate(b *testing.B) {
+	validator := benchmarkValidator(b, benchmarkSchema, ValidatorConfig{MaxStackDepth: DefaultMaxStackDepth})
+	evaluator := validator.NewEvaluator()
+	instance := benchmarkInstance(b, benchmarkValidInstance)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := evaluator.Validate(instance); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkValidator(b *testing.B, schema string, config ValidatorConfig) Validator {
+	var value interface{}
+	if err := json.Unmarshal([]byte(schema), &value); err != nil {
+		b.Fatal(err)
+	}
+
+	validator, err := NewValidatorWithConfig([]interface{}{value}, config)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return validator
+}
+
+func benchmarkInstance(b *testing.B, instance string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(instance), &value); err != nil {
+		b.Fatal(err)
+	}
+
+	return value
+}
+
+func BenchmarkValidatorLargeObject(b *testing.B) {
+	properties := map[string]interface{}{}
+	instance := map[string]interface{}{}
+	for i := 0; i < 100; i++ {
+		properties["declared"+strconv.Itoa(i)] = map[string]interface{}{"type": "string", "maxLength": 64.0}
+		instance["declared"+strconv.Itoa(i)] = "value " + strconv.Itoa(i)
+		instance["extra"+strconv.Itoa(i)] = float64(i)
+	}
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": map[string]interface{}{"type": "number"},
+	}
+
+	benchmarkValidate(b, schema, instance, ValidatorConfig{MaxStackDepth: DefaultMaxStackDepth})
+}
+
+func BenchmarkValidatorDeepRef(b *testing.B) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"value": map[string]interface{}{"type": "integer"},
+			"next":  map[string]interface{}{"$ref": "#"},
+		},
+	}
+
+	for _, depth := range []int{10, 100} {
+		b.Run(strconv.Itoa(depth), func(b *testing.B) {
+			var instance interface{} = map[string]interface{}{"value": 0.0}
+			for i := 1; i < depth; i++ {
+				instance = map[string]interface{}{"value": float64(i), "next": instance}
+			}
+
+			benchmarkValidate(b, schema, instance, ValidatorConfig{MaxStackDepth: 2 * depth})
+		})
+	}
+}
+
+func BenchmarkValidatorWideOneOf(b *testing.B) {
+	branches := []interface{}{}
+	for i := 0; i < 50; i++ {
+		branches = append(branches, map[string]interface{}{
+			"required": []interface{}{"kind"},
+			"properties": map[string]interface{}{
+				"kind":  map[string]interface{}{"const": "kind" + strconv.Itoa(i)},
+				"value": map[string]interface{}{"type": "number"},
+			},
+		})
+	}
+
+	schema := map[string]interface{}{"oneOf": branches}
+	instance := map[string]interface{}{"kind": "kind49", "value": 1.0}
+
+	benchmarkValidate(b, schema, instance, ValidatorConfig{MaxStackDepth: DefaultMaxStackDepth})
+}
+
+func BenchmarkValidatorUniqueItems(b *testing.B) {
+	schema := map[string]interface{}{"uniqueItems": true}
+
+	for _, length := range []int{10, 100, 1000} {
+		b.Run(strconv.Itoa(length), func(b *testing.B) {
+			instance := []interface{}{}
+			for i := 0; i < length; i++ {
+				instance = append(instance, map[string]interface{}{"id": float64(i)})
+			}
+
+			benchmarkValidate(b, schema, instance, ValidatorConfig{MaxStackDepth: DefaultMaxStackDepth})
+		})
+	}
+}
+
+func BenchmarkValidatorEnum(b *testing.B) {
+	for _, length := range []int{10, 1000} {
+		b.Run(strconv.Itoa(length), func(b *testing.B) {
+			values := []interface{}{}
+			for i := 0; i < length; i++ {
+				values = append(values, fmt.Sprintf("SKU-%06d", i))
+			}
+
+			schema := map[string]interface{}{"enum": values}
+			instance := values[length-1]
+
+			benchmarkValidate(b, schema, instance, ValidatorConfig{MaxStackDepth: DefaultMaxStackDepth})
+		})
+	}
+}
+
+func BenchmarkValidatorMemoize(b *testing.B) {
+	kinds := []interface{}{map[string]interface{}{"type": "number"}}
+	for _, op := range []string{"+", "-", "*", "/"} {
+		kinds = append(kinds, map[string]interface{}{
+			"type":     "object",
+			"required": []interface{}{"op", "left", "right"},
+			"properties": map[string]interface{}{
+				"op":    map[string]interface{}{"const": op},
+				"left":  map[string]interface{}{"$ref": "#"},
+				"right": map[string]interface{}{"$ref": "#"},
+			},
+		})
+	}
+
+	schema := map[string]interface{}{"oneOf": kinds}
+
+	var instance interface{} = 1.0
+	for i := 0; i < 6; i++ {
+		instance = map[string]interface{}{"op": "/", "left": instance, "right": 1.0}
+	}
+
+	for _, memoize := range []bool{false, true} {
+		b.Run(strconv.FormatBool(memoize), func(b *testing.B) {
+			benchmarkValidate(b, schema, instance, ValidatorConfig{MaxStackDepth: DefaultMaxStackDepth, Memoize: memoize})
+		})
+	}
+}
+
+func BenchmarkValidatorPatterns(b *testing.B) {
+	patternProperties := map[string]interface{}{}
+	for i := 0; i < 20; i++ {
+		pattern := fmt.Sprintf("^x%d-[a-z]+$", i)
+		patternProperties[pattern] = map[string]interface{}{
+			"type":    "string",
+			"pattern": "^[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\\.[A-Za-z]{2,}$",
+		}
+	}
+
+	schema := map[string]interface{}{
+		"patternProperties":    patternProperties,
+		"additionalProperties": false,
+	}
+
+	instance := map[string]interface{}{}
+	for i := 0; i < 100; i++ {
+		instance[fmt.Sprintf("x%d-key%c", i%20, 'a'+i/20)] = "someone@example.com"
+	}
+
+	benchmarkValidate(b, schema, instance, ValidatorConfig{MaxStackDepth: DefaultMaxStackDepth})
+}
+
+func BenchmarkVMReportError(b *testing.B) {
+	validator := benchmarkValidator(b, `{}`, ValidatorConfig{MaxStackDepth: DefaultMaxStackDepth})
+	vm := validator.getVM(context.Background())
+	defer validator.putVM(vm)
+
+	vm.pushNewSchema(url.URL{}, []string{"properties", "a"})
+	vm.stack.instance = append(vm.stack.instance, token{key: "a"})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		vm.errors.errors = vm.errors.errors[:0]
+		if err := vm.reportKeywordError("type"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVMPushSchemaToken(b *testing.B) {
+	validator := benchmarkValidator(b, `{}`, ValidatorConfig{MaxStackDepth: DefaultMaxStackDepth})
+	vm := validator.getVM(context.Background())
+	defer validator.putVM(vm)
+
+	vm.pushNewSchema(url.URL{}, []string{})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		vm.pushSchemaToken("properties")
+		vm.pushSchemaToken("a")
+		vm.popSchemaToken()
+		vm.popSchemaToken()
+	}
+}
+
+func BenchmarkVMPseudoExec(b *testing.B) {
+	benchmarks := []struct {
+		name     string
+		instance interface{}
+	}{
+		{"accept", "foo"},
+		{"reject", 3.0},
+	}
+
+	for _, bb := range benchmarks {
+		b.Run(bb.name, func(b *testing.B) {
+			validator := benchmarkValidator(b, `{"type": "string"}`, ValidatorConfig{MaxStackDepth: DefaultMaxStackDepth})
+			vm := validator.getVM(context.Background())
+			defer validator.putVM(vm)
+
+			index := validator.registry.schemas[url.URL{}]
+			vm.pushNewSchema(url.URL{}, []string{})
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := vm.pseudoExec(index, bb.instance); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// benchmarkValidate benchmarks validating instance against schema.
+func benchmarkValidate(b *testing.B, schema, instance interface{}, config ValidatorConfig) {
+	validator, err := NewValidatorWithConfig([]interface{}{schema}, config)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := validator.Validate(instance); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
-	"sort"
 	"testing"
 
 	jsonpointer "github.com/json-schema-spec/json-pointer-go"
@@ -30,6 +29,7 @@ type instanceError struct {
 	InstancePath string `json:"instancePath"`
 	SchemaPath   string `json:"schemaPath"`
 	URI          string `json:"uri"`
+	Duplicates   []int  `json:"duplicates"`
 }
 
 func TestValidatorSpec(t *testing.T) {
@@ -62,6 +62,13 @@ func TestValidatorSpec(t *testing.T) {
 						return
 					}
 
+					memoized, err := NewValidatorWithConfig(schemas, ValidatorConfig{
+						MaxStackDepth: DefaultMaxStackDepth,
+						Epsilon:       DefaultEpsilon,
+						Memoize:       true,
+					})
+					assert.Nil(t, err)
+
 					for i, instance := range tt.Instances {
 						t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
 							result, err := validator.Validate(instance.Instance)
@@ -77,31 +84,18 @@ func TestValidatorSpec(t *testing.T) {
 									InstancePath: instancePath,
 									SchemaPath:   schemaPath,
 									URI:          *uri,
+									Duplicates:   e.Duplicates,
 								}
 							}
 
-							sort.Slice(expected, func(i, j int) bool {
-								a := expected[i]
-								b := expected[j]
-
-								if a.SchemaPath.String() == b.SchemaPath.String() {
-									return a.InstancePath.String() < b.InstancePath.String()
-								}
-
-								return a.SchemaPath.String() < b.SchemaPath.String()
-							})
-
-							sort.Slice(result.Errors, func(i, j int) bool {
-								a := result.Errors[i]
-								b := result.Errors[j]
-
-								if a.SchemaPath.String() == b.SchemaPath.String() {
-									return a.InstancePath.String() < b.InstancePath.String()
-								}
+							assert.Equal(t, expected, result.Errors)
 
-								return a.SchemaPath.String() < b.SchemaPath.String()
-							})
+							valid, err := validator.IsValid(instance.Instance)
+							assert.Nil(t, err)
+							assert.Equal(t, len(expected) == 0, valid)
 
+							result, err = memoized.Validate(instance.Instance)
+							assert.Nil(t, err)
 							assert.Equal(t, expected, result.Errors)
 						})
 					}
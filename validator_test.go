@@ -1,8 +1,13 @@
 package jsonschema
 
 import (
+	"context"
+	"encoding/json"
 	"net/url"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	jsonpointer "github.com/json-schema-spec/json-pointer-go"
 	"github.com/stretchr/testify/assert"
@@ -113,6 +118,15 @@ func TestValidatorSeal(t *testing.T) {
 			},
 			ErrInvalidSchema,
 		},
+		{
+			"non-positive multipleOf value",
+			[]interface{}{
+				map[string]interface{}{
+					"multipleOf": float64(0),
+				},
+			},
+			ErrInvalidSchema,
+		},
 		{
 			"non-number maximum value",
 			[]interface{}{
@@ -167,6 +181,24 @@ func TestValidatorSeal(t *testing.T) {
 			},
 			ErrInvalidSchema,
 		},
+		{
+			"nearly-int maxLength value",
+			[]interface{}{
+				map[string]interface{}{
+					"maxLength": 2.9995,
+				},
+			},
+			ErrInvalidSchema,
+		},
+		{
+			"float with int value maxLength value",
+			[]interface{}{
+				map[string]interface{}{
+					"maxLength": 3.0,
+				},
+			},
+			nil,
+		},
 		{
 			"non-positive maxLength value",
 			[]interface{}{
@@ -571,17 +603,256 @@ func TestValidatorSeal(t *testing.T) {
 func TestValidatorOverflow(t *testing.T) {
 	schemas := []interface{}{
 		map[string]interface{}{
-			"$ref": "#",
+			"anyOf": []interface{}{
+				map[string]interface{}{"type": "string"},
+				map[string]interface{}{"$ref": "#"},
+			},
 		},
 	}
 
 	validator, err := NewValidator(schemas)
 	assert.NoError(t, err)
 
+	result, err := validator.Validate("foo")
+	assert.NoError(t, err)
+	assert.True(t, result.IsValid())
+
 	_, err = validator.Validate(nil)
 	assert.Equal(t, ErrStackOverflow, err)
 }
 
+func TestValidatorDeepRecursion(t *testing.T) {
+	// a thread of comments, each replying to the one before
+	schemas := []interface{}{
+		map[string]interface{}{
+			"type":     "object",
+			"required": []interface{}{"text"},
+			"properties": map[string]interface{}{
+				"text":    map[string]interface{}{"$ref": "#/definitions/text"},
+				"replies": map[string]interface{}{"items": map[string]interface{}{"$ref": "#"}},
+			},
+			"definitions": map[string]interface{}{
+				"text": map[string]interface{}{"type": "string"},
+			},
+		},
+	}
+
+	thread := map[string]interface{}{"text": "200"}
+	for i := 199; i > 0; i-- {
+		thread = map[string]interface{}{
+			"text":    strconv.Itoa(i),
+			"replies": []interface{}{thread},
+		}
+	}
+
+	validator, err := NewValidatorWithConfig(schemas, ValidatorConfig{
+		MaxStackDepth: 3,
+	})
+	assert.NoError(t, err)
+
+	result, err := validator.Validate(thread)
+	assert.NoError(t, err)
+	assert.True(t, result.IsValid())
+
+	valid, err := validator.IsValid(thread)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	validator, err = NewValidatorWithConfig(schemas, ValidatorConfig{
+		MaxStackDepth:    3,
+		MaxInstanceDepth: 100,
+	})
+	assert.NoError(t, err)
+
+	_, err = validator.Validate(thread)
+	assert.Equal(t, ErrBudgetExceeded{Budget: BudgetInstanceDepth, Limit: 100}, err)
+
+	// the default limits allow the thread
+	validator, err = NewValidator(schemas)
+	assert.NoError(t, err)
+
+	result, err = validator.Validate(thread)
+	assert.NoError(t, err)
+	assert.True(t, result.IsValid())
+
+	// recursion through contains descends into the instance just as items does
+	for _, keyword := range []string{"items", "contains"} {
+		schemas = []interface{}{
+			map[string]interface{}{
+				"anyOf": []interface{}{
+					map[string]interface{}{"maxItems": 0.0},
+					map[string]interface{}{keyword: map[string]interface{}{"$ref": "#"}},
+				},
+			},
+		}
+
+		var nested interface{} = []interface{}{}
+		for i := 0; i < 200; i++ {
+			nested = []interface{}{nested}
+		}
+
+		validator, err = NewValidator(schemas)
+		assert.NoError(t, err)
+
+		result, err = validator.Validate(nested)
+		assert.NoError(t, err, keyword)
+		assert.True(t, result.IsValid(), keyword)
+	}
+}
+
+func TestValidatorRefCycle(t *testing.T) {
+	testCases := []struct {
+		name    string
+		schemas []interface{}
+		uris    []string
+	}{
+		{
+			"self",
+			[]interface{}{
+				map[string]interface{}{"$ref": "#"},
+			},
+			[]string{""},
+		},
+		{
+			"through allOf",
+			[]interface{}{
+				map[string]interface{}{
+					"allOf": []interface{}{
+						map[string]interface{}{"type": "null"},
+						map[string]interface{}{"$ref": "#"},
+					},
+				},
+			},
+			[]string{""},
+		},
+		{
+			"through definitions",
+			[]interface{}{
+				map[string]interface{}{
+					"definitions": map[string]interface{}{
+						"a": map[string]interface{}{"not": map[string]interface{}{"$ref": "#/definitions/b"}},
+						"b": map[string]interface{}{"oneOf": []interface{}{map[string]interface{}{"$ref": "#/definitions/a"}}},
+					},
+					"$ref": "#/definitions/a",
+				},
+			},
+			[]string{"#/definitions/b", "#/definitions/a"},
+		},
+		{
+			"across schemas",
+			[]interface{}{
+				map[string]interface{}{"$ref": "http://example.com/a"},
+				map[string]interface{}{
+					"$id":  "http://example.com/a",
+					"if":   map[string]interface{}{"$ref": "http://example.com/b"},
+					"then": true,
+				},
+				map[string]interface{}{
+					"$id":   "http://example.com/b",
+					"anyOf": []interface{}{map[string]interface{}{"$ref": "http://example.com/a"}},
+				},
+			},
+			[]string{"http://example.com/b", "http://example.com/a"},
+		},
+		{
+			"through properties",
+			[]interface{}{
+				map[string]interface{}{
+					"properties": map[string]interface{}{
+						"a": map[string]interface{}{"$ref": "#"},
+					},
+				},
+			},
+			nil,
+		},
+		{
+			"through later anyOf branch",
+			[]interface{}{
+				map[string]interface{}{
+					"anyOf": []interface{}{
+						map[string]interface{}{"type": "string"},
+						map[string]interface{}{"$ref": "#"},
+					},
+				},
+			},
+			nil,
+		},
+		{
+			"through later oneOf branch",
+			[]interface{}{
+				map[string]interface{}{
+					"oneOf": []interface{}{
+						map[string]interface{}{"type": "string"},
+						map[string]interface{}{"type": "string"},
+						map[string]interface{}{"$ref": "#"},
+					},
+				},
+			},
+			nil,
+		},
+		{
+			"through then",
+			[]interface{}{
+				map[string]interface{}{
+					"if":   map[string]interface{}{"type": "string"},
+					"then": map[string]interface{}{"$ref": "#"},
+				},
+			},
+			nil,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewValidator(tt.schemas)
+			if tt.uris == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			uris := []url.URL{}
+			for _, s := range tt.uris {
+				uri, err := url.Parse(s)
+				assert.NoError(t, err)
+				uris = append(uris, *uri)
+			}
+
+			assert.Equal(t, ErrRefCycle{URIs: uris}, err)
+		})
+	}
+}
+
+func TestValidatorRefCycleLaterOneOfBranch(t *testing.T) {
+	validator, err := NewValidator([]interface{}{
+		map[string]interface{}{
+			"oneOf": []interface{}{
+				map[string]interface{}{"type": "string"},
+				map[string]interface{}{"type": "string"},
+				map[string]interface{}{"$ref": "#"},
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	// strings match the first two branches, so the last is never evaluated
+	result, err := validator.Validate("a")
+	assert.NoError(t, err)
+	assert.False(t, result.IsValid())
+
+	// other instances follow the last branch until the stack overflows
+	_, err = validator.Validate(1.0)
+	assert.Equal(t, ErrStackOverflow, err)
+}
+
+func TestErrRefCycle(t *testing.T) {
+	err := ErrRefCycle{URIs: []url.URL{
+		url.URL{Scheme: "http", Host: "example.com", Path: "/a"},
+		url.URL{Scheme: "http", Host: "example.com", Path: "/b", Fragment: "/definitions/c"},
+	}}
+
+	assert.Equal(t, "cyclical $ref: http://example.com/a# -> http://example.com/b#/definitions/c -> http://example.com/a#", err.Error())
+}
+
 func TestValidatorMaxErrors(t *testing.T) {
 	schemas := []interface{}{
 		map[string]interface{}{
@@ -590,32 +861,130 @@ func TestValidatorMaxErrors(t *testing.T) {
 					"type": "null",
 				},
 				map[string]interface{}{
-					"$ref": "#",
+					"items": map[string]interface{}{
+						"$ref": "#",
+					},
 				},
 			},
 		},
 	}
 
-	validationError := ValidationError{
-		InstancePath: jsonpointer.Ptr{Tokens: []string{}},
-		SchemaPath:   jsonpointer.Ptr{Tokens: []string{"allOf", "0", "type"}},
-	}
-
 	expectedResult := []ValidationError{}
+	instancePath := []string{}
 	for i := 0; i < 5; i++ {
-		expectedResult = append(expectedResult, validationError)
+		expectedResult = append(expectedResult, ValidationError{
+			InstancePath: jsonpointer.Ptr{Tokens: instancePath},
+			SchemaPath:   jsonpointer.Ptr{Tokens: []string{"allOf", "0", "type"}},
+		})
+
+		instancePath = append(instancePath[:len(instancePath):len(instancePath)], "0")
+	}
+
+	var instance interface{} = []interface{}{}
+	for i := 0; i < 8; i++ {
+		instance = []interface{}{instance}
 	}
 
 	validator, err := NewValidatorWithConfig(schemas, ValidatorConfig{
 		MaxErrors:     5,
-		MaxStackDepth: 10, // max depth > errors, so no stack overflow should occur
+		MaxStackDepth: DefaultMaxStackDepth,
 	})
 
 	assert.NoError(t, err)
 
-	result, err := validator.Validate(true)
+	result, err := validator.Validate(instance)
 	assert.NoError(t, err)
 	assert.Equal(t, expectedResult, result.Errors)
+	assert.True(t, result.Overflowed)
+	assert.Equal(t, 5, result.ErrorCount)
+}
+
+func TestValidatorMaxErrorsNotReached(t *testing.T) {
+	schemas := []interface{}{
+		map[string]interface{}{
+			"items": map[string]interface{}{
+				"type": "null",
+			},
+		},
+	}
+
+	validator, err := NewValidatorWithConfig(schemas, ValidatorConfig{
+		MaxErrors: 3,
+	})
+
+	assert.NoError(t, err)
+
+	result, err := validator.Validate([]interface{}{true, true})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(result.Errors))
+	assert.False(t, result.Overflowed)
+	assert.Equal(t, 2, result.ErrorCount)
+}
+
+func TestValidatorMaxErrorsPseudoExec(t *testing.T) {
+	schemas := []interface{}{
+		map[string]interface{}{
+			"anyOf": []interface{}{
+				map[string]interface{}{
+					"type": "string",
+				},
+				map[string]interface{}{
+					"type": "null",
+				},
+			},
+		},
+	}
+
+	validator, err := NewValidatorWithConfig(schemas, ValidatorConfig{
+		MaxErrors: 1,
+	})
+
+	assert.NoError(t, err)
+
+	// errors from anyOf branches which are discarded must not count towards
+	// MaxErrors
+	result, err := validator.Validate(nil)
+	assert.NoError(t, err)
+	assert.True(t, result.IsValid())
+	assert.False(t, result.Overflowed)
+}
+
+func TestValidatorCountAllErrors(t *testing.T) {
+	schemas := []interface{}{
+		map[string]interface{}{
+			"items": map[string]interface{}{
+				"type": "null",
+			},
+		},
+	}
+
+	validator, err := NewValidatorWithConfig(schemas, ValidatorConfig{
+		MaxErrors:      2,
+		CountAllErrors: true,
+	})
+
+	assert.NoError(t, err)
+
+	result, err := validator.Validate([]interface{}{true, true, nil, true, true})
+	assert.NoError(t, err)
+	assert.Equal(t, []ValidationError{
+		ValidationError{
+			InstancePath: jsonpointer.Ptr{Tokens: []string{"0"}},
+			SchemaPath:   jsonpointer.Ptr{Tokens: []string{"items", "type"}},
+		},
+		ValidationError{
+			InstancePath: jsonpointer.Ptr{Tokens: []string{"1"}},
+			SchemaPath:   jsonpointer.Ptr{Tokens: []string{"items", "type"}},
+		},
+	}, result.Errors)
+	assert.True(t, result.Overflowed)
+	assert.Equal(t, 4, result.ErrorCount)
+
+	result, err = validator.Validate([]interface{}{nil, true})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(result.Errors))
+	assert.False(t, result.Overflowed)
+	assert.Equal(t, 1, result.ErrorCount)
 }
 
 func TestValidatorIsValid(t *testing.T) {
@@ -631,6 +1000,62 @@ func TestValidatorIsValid(t *testing.T) {
 	assert.False(t, invalid.IsValid())
 }
 
+func TestValidatorIsValidShortCircuits(t *testing.T) {
+	// each element fails, so full evaluation uses a keyword per element, but
+	// IsValid stops at the first
+	validator, err := NewValidatorWithConfig([]interface{}{
+		map[string]interface{}{"items": map[string]interface{}{"type": "string"}},
+	}, ValidatorConfig{MaxStackDepth: DefaultMaxStackDepth, MaxFuel: 10})
+	assert.NoError(t, err)
+
+	instance := make([]interface{}, 100)
+
+	_, err = validator.Validate(instance)
+	assert.Equal(t, ErrBudgetExceeded{Budget: BudgetFuel, Limit: 10}, err)
+
+	valid, err := validator.IsValid(instance)
+	assert.NoError(t, err)
+	assert.False(t, valid)
+}
+
+func TestValidatorIsValidUnwinds(t *testing.T) {
+	// the first branch of anyOf fails after following a $ref and descending
+	// into the instance; if that were not undone, the stack and instance depth
+	// limits would be reached
+	validator, err := NewValidatorWithConfig([]interface{}{
+		map[string]interface{}{
+			"definitions": map[string]interface{}{
+				"strings": map[string]interface{}{
+					"items": map[string]interface{}{"type": "string"},
+				},
+			},
+			"items": map[string]interface{}{
+				"anyOf": []interface{}{
+					map[string]interface{}{"$ref": "#/definitions/strings"},
+					true,
+				},
+			},
+		},
+	}, ValidatorConfig{MaxStackDepth: 3, MaxInstanceDepth: 3})
+	assert.NoError(t, err)
+
+	instance := []interface{}{}
+	for i := 0; i < 10; i++ {
+		instance = append(instance, []interface{}{1.0})
+	}
+
+	valid, err := validator.IsValid(instance)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	valid, err = validator.IsValidURI(url.URL{Fragment: "/definitions/strings"}, []interface{}{"a", 1.0})
+	assert.NoError(t, err)
+	assert.False(t, valid)
+
+	_, err = validator.IsValidURI(url.URL{Host: "example.com"}, nil)
+	assert.Equal(t, ErrNoSuchSchema, err)
+}
+
 func TestValidatorValidateURI(t *testing.T) {
 	schemas := []interface{}{
 		map[string]interface{}{
@@ -666,3 +1091,803 @@ func TestValidatorValidateURI(t *testing.T) {
 	_, err = validator.ValidateURI(*uriBaz, nil)
 	assert.Equal(t, ErrNoSuchSchema, err)
 }
+
+func TestValidatorSubSchemaURIs(t *testing.T) {
+	schemas := []interface{}{
+		map[string]interface{}{
+			"paths": map[string]interface{}{
+				"/users": map[string]interface{}{
+					"schema": map[string]interface{}{
+						"type": "string",
+					},
+				},
+			},
+		},
+	}
+
+	uri := url.URL{Fragment: "/paths/~1users/schema"}
+
+	validator, err := NewValidator(schemas)
+	assert.NoError(t, err)
+
+	_, err = validator.ValidateURI(uri, nil)
+	assert.Equal(t, ErrNoSuchSchema, err)
+
+	validator, err = NewValidatorWithConfig(schemas, ValidatorConfig{
+		MaxStackDepth: DefaultMaxStackDepth,
+		SubSchemaURIs: []url.URL{uri},
+	})
+	assert.NoError(t, err)
+
+	result, err := validator.ValidateURI(uri, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []ValidationError{
+		ValidationError{
+			InstancePath: jsonpointer.Ptr{Tokens: []string{}},
+			SchemaPath:   jsonpointer.Ptr{Tokens: []string{"paths", "/users", "schema", "type"}},
+			URI:          uri,
+		},
+	}, result.Errors)
+
+	_, err = NewValidatorWithConfig(schemas, ValidatorConfig{
+		SubSchemaURIs: []url.URL{url.URL{Scheme: "http", Host: "example.com"}},
+	})
+	assert.IsType(t, ErrMissingURIs{}, err)
+}
+
+func TestValidatorResolveRef(t *testing.T) {
+	validator, err := NewValidator([]interface{}{
+		map[string]interface{}{
+			"properties": map[string]interface{}{
+				"a": map[string]interface{}{"$ref": "#/definitions/a"},
+				"b": map[string]interface{}{"$ref": "http://example.com/b"},
+				"c": map[string]interface{}{},
+			},
+			"definitions": map[string]interface{}{
+				"a": map[string]interface{}{},
+			},
+		},
+		map[string]interface{}{"$id": "http://example.com/b"},
+	})
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		uri string
+		ref string
+		ok  bool
+	}{
+		{"#/properties/a", "#/definitions/a", true},
+		{"#/properties/b", "http://example.com/b", true},
+		{"#/properties/c", "", false},
+		{"#/properties/d", "", false},
+		{"http://example.com/b", "", false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.uri, func(t *testing.T) {
+			uri, err := url.Parse(tt.uri)
+			assert.NoError(t, err)
+
+			ref, ok := validator.ResolveRef(*uri)
+			assert.Equal(t, tt.ref, ref.String())
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
+
+func TestValidatorValidateContext(t *testing.T) {
+	schemas := []interface{}{
+		map[string]interface{}{
+			"items": map[string]interface{}{
+				"$ref": "#",
+			},
+			"uniqueItems": true,
+		},
+	}
+
+	validator, err := NewValidator(schemas)
+	assert.NoError(t, err)
+
+	result, err := validator.ValidateContext(context.Background(), []interface{}{})
+	assert.NoError(t, err)
+	assert.True(t, result.IsValid())
+
+	instance := []interface{}{}
+	for i := 0; i < 1000; i++ {
+		instance = append(instance, []interface{}{float64(i)})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = validator.ValidateContext(ctx, instance)
+	assert.Equal(t, context.Canceled, err)
+
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	_, err = validator.ValidateURIContext(ctx, url.URL{}, instance)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// a context cancelled once evaluation is underway must stop it part way
+	// through the instance
+	startCtx := newCancelOnStartContext()
+	_, err = validator.ValidateContext(startCtx, instance)
+	assert.Equal(t, context.Canceled, err)
+
+	startCtx = newCancelOnStartContext()
+	_, err = validator.IsValidContext(startCtx, instance)
+	assert.Equal(t, context.Canceled, err)
+}
+
+// cancelOnStartContext is a context which is cancelled the first time its Err
+// method is called, which the validator does just before it begins evaluating
+// an instance. It lets tests cancel a context mid-evaluation without racing a
+// timer against the validator.
+type cancelOnStartContext struct {
+	context.Context
+	cancel   context.CancelFunc
+	errCalls int
+}
+
+func newCancelOnStartContext() *cancelOnStartContext {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &cancelOnStartContext{Context: ctx, cancel: cancel}
+}
+
+func (c *cancelOnStartContext) Err() error {
+	c.errCalls++
+	if c.errCalls == 1 {
+		err := c.Context.Err()
+		c.cancel()
+		return err
+	}
+
+	return c.Context.Err()
+}
+
+func TestValidatorBudgets(t *testing.T) {
+	nested := interface{}(nil)
+	for i := 0; i < 10; i++ {
+		nested = []interface{}{nested}
+	}
+
+	testCases := []struct {
+		name     string
+		schema   interface{}
+		config   ValidatorConfig
+		instance interface{}
+		err      error
+	}{
+		{
+			"instance depth within limit",
+			map[string]interface{}{"items": map[string]interface{}{"$ref": "#"}},
+			ValidatorConfig{MaxStackDepth: 128, MaxInstanceDepth: 10},
+			nested,
+			nil,
+		},
+		{
+			"instance depth exceeded",
+			map[string]interface{}{"items": map[string]interface{}{"$ref": "#"}},
+			ValidatorConfig{MaxStackDepth: 128, MaxInstanceDepth: 9},
+			nested,
+			ErrBudgetExceeded{Budget: BudgetInstanceDepth, Limit: 9},
+		},
+		{
+			"instance depth within limit through contains",
+			map[string]interface{}{"contains": map[string]interface{}{"$ref": "#"}},
+			ValidatorConfig{MaxStackDepth: 128, MaxInstanceDepth: 10},
+			nested,
+			nil,
+		},
+		{
+			"instance depth exceeded through contains",
+			map[string]interface{}{"contains": map[string]interface{}{"$ref": "#"}},
+			ValidatorConfig{MaxStackDepth: 128, MaxInstanceDepth: 9},
+			nested,
+			ErrBudgetExceeded{Budget: BudgetInstanceDepth, Limit: 9},
+		},
+		{
+			"instance depth only counts values evaluated",
+			map[string]interface{}{},
+			ValidatorConfig{MaxInstanceDepth: 1},
+			nested,
+			nil,
+		},
+		{
+			"nodes within limit",
+			map[string]interface{}{"items": map[string]interface{}{}},
+			ValidatorConfig{MaxNodes: 4},
+			[]interface{}{nil, nil, nil},
+			nil,
+		},
+		{
+			"nodes exceeded",
+			map[string]interface{}{"items": map[string]interface{}{}},
+			ValidatorConfig{MaxNodes: 3},
+			[]interface{}{nil, nil, nil},
+			ErrBudgetExceeded{Budget: BudgetNodes, Limit: 3},
+		},
+		{
+			"nodes within limit through contains",
+			map[string]interface{}{"contains": map[string]interface{}{"type": "string"}},
+			ValidatorConfig{MaxNodes: 4},
+			[]interface{}{nil, nil, nil},
+			nil,
+		},
+		{
+			"nodes exceeded through contains",
+			map[string]interface{}{"contains": map[string]interface{}{"type": "string"}},
+			ValidatorConfig{MaxNodes: 3},
+			[]interface{}{nil, nil, nil},
+			ErrBudgetExceeded{Budget: BudgetNodes, Limit: 3},
+		},
+		{
+			"nodes counted on each visit",
+			map[string]interface{}{
+				"anyOf": []interface{}{
+					map[string]interface{}{"items": map[string]interface{}{"type": "string"}},
+					map[string]interface{}{"items": map[string]interface{}{"type": "null"}},
+				},
+			},
+			ValidatorConfig{MaxNodes: 4},
+			[]interface{}{nil, nil, nil},
+			ErrBudgetExceeded{Budget: BudgetNodes, Limit: 4},
+		},
+		{
+			"fuel within limit",
+			map[string]interface{}{"type": "null", "not": map[string]interface{}{"type": "string"}},
+			ValidatorConfig{MaxFuel: 3},
+			nil,
+			nil,
+		},
+		{
+			"fuel exceeded",
+			map[string]interface{}{"type": "null", "not": map[string]interface{}{"type": "string"}},
+			ValidatorConfig{MaxFuel: 2},
+			nil,
+			ErrBudgetExceeded{Budget: BudgetFuel, Limit: 2},
+		},
+		{
+			"string length within limit",
+			map[string]interface{}{},
+			ValidatorConfig{MaxStringLength: 3},
+			"foo",
+			nil,
+		},
+		{
+			"string length exceeded",
+			map[string]interface{}{},
+			ValidatorConfig{MaxStringLength: 3},
+			"fooo",
+			ErrBudgetExceeded{Budget: BudgetStringLength, Limit: 3},
+		},
+		{
+			"array length within limit",
+			map[string]interface{}{},
+			ValidatorConfig{MaxArrayLength: 3},
+			[]interface{}{nil, nil, nil},
+			nil,
+		},
+		{
+			"array length exceeded",
+			map[string]interface{}{},
+			ValidatorConfig{MaxArrayLength: 3},
+			[]interface{}{nil, nil, nil, nil},
+			ErrBudgetExceeded{Budget: BudgetArrayLength, Limit: 3},
+		},
+		{
+			"string length exceeded with true schema",
+			true,
+			ValidatorConfig{MaxStringLength: 3},
+			"fooo",
+			ErrBudgetExceeded{Budget: BudgetStringLength, Limit: 3},
+		},
+		{
+			"array length exceeded with true schema",
+			true,
+			ValidatorConfig{MaxArrayLength: 3},
+			[]interface{}{nil, nil, nil, nil},
+			ErrBudgetExceeded{Budget: BudgetArrayLength, Limit: 3},
+		},
+		{
+			"string length exceeded within true items",
+			map[string]interface{}{"items": true},
+			ValidatorConfig{MaxStringLength: 3},
+			[]interface{}{"foo", "fooo"},
+			ErrBudgetExceeded{Budget: BudgetStringLength, Limit: 3},
+		},
+		{
+			"array length exceeded within true property",
+			map[string]interface{}{"properties": map[string]interface{}{"a": true}},
+			ValidatorConfig{MaxArrayLength: 3},
+			map[string]interface{}{"a": []interface{}{nil, nil, nil, nil}},
+			ErrBudgetExceeded{Budget: BudgetArrayLength, Limit: 3},
+		},
+		{
+			"string length exceeded within empty items",
+			map[string]interface{}{"items": map[string]interface{}{}},
+			ValidatorConfig{MaxStringLength: 3},
+			[]interface{}{"fooo"},
+			ErrBudgetExceeded{Budget: BudgetStringLength, Limit: 3},
+		},
+		{
+			"string length exceeded by property name",
+			map[string]interface{}{"propertyNames": true},
+			ValidatorConfig{MaxStringLength: 3},
+			map[string]interface{}{"fooo": nil},
+			ErrBudgetExceeded{Budget: BudgetStringLength, Limit: 3},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			validator, err := NewValidatorWithConfig([]interface{}{tt.schema}, tt.config)
+			assert.NoError(t, err)
+
+			_, err = validator.Validate(tt.instance)
+			assert.Equal(t, tt.err, err)
+		})
+	}
+}
+
+func TestValidatorDeterministicErrors(t *testing.T) {
+	schemas := []interface{}{
+		map[string]interface{}{
+			"patternProperties": map[string]interface{}{
+				"^a": map[string]interface{}{"type": "string"},
+				"b$": map[string]interface{}{"type": "string"},
+			},
+			"dependencies": map[string]interface{}{
+				"ab": []interface{}{"z"},
+				"b":  []interface{}{"z"},
+				"a":  []interface{}{"z"},
+			},
+			"propertyNames": map[string]interface{}{
+				"maxLength": float64(0),
+			},
+		},
+	}
+
+	validator, err := NewValidator(schemas)
+	assert.NoError(t, err)
+
+	instance := map[string]interface{}{
+		"b":  nil,
+		"ab": nil,
+		"a":  nil,
+	}
+
+	expected := [][]string{
+		{"/a", "/patternProperties/^a/type"},
+		{"/ab", "/patternProperties/^a/type"},
+		{"/ab", "/patternProperties/b$/type"},
+		{"/b", "/patternProperties/b$/type"},
+		{"", "/dependencies/a/0"},
+		{"", "/dependencies/ab/0"},
+		{"", "/dependencies/b/0"},
+		{"/a", "/propertyNames/maxLength"},
+		{"/ab", "/propertyNames/maxLength"},
+		{"/b", "/propertyNames/maxLength"},
+	}
+
+	for i := 0; i < 10; i++ {
+		result, err := validator.Validate(instance)
+		assert.NoError(t, err)
+
+		actual := [][]string{}
+		for _, e := range result.Errors {
+			actual = append(actual, []string{e.InstancePath.String(), e.SchemaPath.String()})
+		}
+
+		assert.Equal(t, expected, actual)
+	}
+}
+
+func TestValidatorDeduplicateErrors(t *testing.T) {
+	schemas := []interface{}{
+		map[string]interface{}{
+			"definitions": map[string]interface{}{
+				"a": map[string]interface{}{
+					"type": "null",
+				},
+			},
+			"allOf": []interface{}{
+				map[string]interface{}{"$ref": "#/definitions/a"},
+				map[string]interface{}{"$ref": "#/definitions/a"},
+			},
+		},
+	}
+
+	validationError := ValidationError{
+		InstancePath: jsonpointer.Ptr{Tokens: []string{}},
+		SchemaPath:   jsonpointer.Ptr{Tokens: []string{"definitions", "a", "type"}},
+	}
+
+	validator, err := NewValidator(schemas)
+	assert.NoError(t, err)
+
+	result, err := validator.Validate(true)
+	assert.NoError(t, err)
+	assert.Equal(t, []ValidationError{validationError, validationError}, result.Errors)
+
+	validator, err = NewValidatorWithConfig(schemas, ValidatorConfig{
+		MaxStackDepth:     DefaultMaxStackDepth,
+		DeduplicateErrors: true,
+	})
+	assert.NoError(t, err)
+
+	result, err = validator.Validate(true)
+	assert.NoError(t, err)
+	assert.Equal(t, []ValidationError{validationError}, result.Errors)
+	assert.Equal(t, 1, result.ErrorCount)
+}
+
+func TestValidatorMemoize(t *testing.T) {
+	// every kind of binary expression has the same shape, so each subtree is
+	// evaluated against every kind, at every level above it
+	kinds := []interface{}{}
+	for _, op := range []string{"+", "-", "*", "/"} {
+		kinds = append(kinds, map[string]interface{}{
+			"type":     "object",
+			"required": []interface{}{"op", "left", "right"},
+			"properties": map[string]interface{}{
+				"op":    map[string]interface{}{"const": op},
+				"left":  map[string]interface{}{"$ref": "#"},
+				"right": map[string]interface{}{"$ref": "#"},
+			},
+		})
+	}
+
+	kinds = append(kinds, map[string]interface{}{"type": "number"})
+
+	schemas := []interface{}{
+		map[string]interface{}{"oneOf": kinds},
+	}
+
+	var instance interface{} = 1.0
+	for i := 0; i < 12; i++ {
+		instance = map[string]interface{}{"op": "/", "left": instance, "right": instance}
+	}
+
+	invalid := map[string]interface{}{"op": "%", "left": instance, "right": instance}
+
+	validator, err := NewValidatorWithConfig(schemas, ValidatorConfig{
+		MaxStackDepth: DefaultMaxStackDepth,
+		MaxFuel:       100000,
+	})
+	assert.NoError(t, err)
+
+	_, err = validator.Validate(instance)
+	assert.Equal(t, ErrBudgetExceeded{Budget: BudgetFuel, Limit: 100000}, err)
+
+	memoized, err := NewValidatorWithConfig(schemas, ValidatorConfig{
+		MaxStackDepth: DefaultMaxStackDepth,
+		MaxFuel:       100000,
+		Memoize:       true,
+	})
+	assert.NoError(t, err)
+
+	result, err := memoized.Validate(instance)
+	assert.NoError(t, err)
+	assert.True(t, result.IsValid())
+
+	valid, err := memoized.IsValid(instance)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	result, err = memoized.Validate(invalid)
+	assert.NoError(t, err)
+	assert.Equal(t, []ValidationError{
+		ValidationError{
+			InstancePath: jsonpointer.Ptr{Tokens: []string{}},
+			SchemaPath:   jsonpointer.Ptr{Tokens: []string{"oneOf"}},
+		},
+	}, result.Errors)
+
+	// the same object is evaluated again by a reused evaluator, whose memo
+	// must not carry over from one instance to the next
+	evaluator := memoized.NewEvaluator()
+	result, err = evaluator.Validate(instance)
+	assert.NoError(t, err)
+	assert.True(t, result.IsValid())
+
+	instance.(map[string]interface{})["op"] = "%"
+	result, err = evaluator.Validate(instance)
+	assert.NoError(t, err)
+	assert.False(t, result.IsValid())
+}
+
+// Validators are meant to be safe for concurrent use. Run these tests with the
+// -race flag to check that guarantee.
+func TestValidatorConcurrent(t *testing.T) {
+	schemas := []interface{}{
+		map[string]interface{}{
+			"items": map[string]interface{}{
+				"anyOf": []interface{}{
+					map[string]interface{}{"type": "null"},
+					map[string]interface{}{"$ref": "#"},
+				},
+			},
+		},
+	}
+
+	validator, err := NewValidatorWithConfig(schemas, ValidatorConfig{
+		MaxStackDepth: DefaultMaxStackDepth,
+		MaxErrors:     2,
+	})
+
+	assert.NoError(t, err)
+
+	instances := []interface{}{
+		[]interface{}{nil, []interface{}{nil}},
+		[]interface{}{true, []interface{}{true}, true},
+		[]interface{}{[]interface{}{[]interface{}{[]interface{}{nil}}}},
+		true,
+	}
+
+	expected := make([]ValidationResult, len(instances))
+	for i, instance := range instances {
+		expected[i], err = validator.Validate(instance)
+		assert.NoError(t, err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+
+		// copies of a validator are equally safe to use concurrently
+		go func(validator Validator) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				index := j % len(instances)
+				result, err := validator.Validate(instances[index])
+				assert.NoError(t, err)
+				assert.Equal(t, expected[index], result)
+			}
+		}(validator)
+	}
+
+	wg.Wait()
+}
+
+func TestValidatorValidateBatch(t *testing.T) {
+	schemas := []interface{}{
+		map[string]interface{}{
+			"type": "null",
+		},
+	}
+
+	validator, err := NewValidator(schemas)
+	assert.NoError(t, err)
+
+	instances := []interface{}{}
+	for i := 0; i < 100; i++ {
+		if i%3 == 0 {
+			instances = append(instances, nil)
+		} else {
+			instances = append(instances, true)
+		}
+	}
+
+	for _, workers := range []int{0, 1, 4, 1000} {
+		results, err := validator.ValidateBatch(instances, workers)
+		assert.NoError(t, err)
+		assert.Equal(t, len(instances), len(results))
+
+		for i, result := range results {
+			assert.Equal(t, i%3 == 0, result.IsValid())
+		}
+	}
+
+	results, err := validator.ValidateBatch([]interface{}{}, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, []ValidationResult{}, results)
+
+	schemas = []interface{}{
+		map[string]interface{}{
+			"anyOf": []interface{}{
+				map[string]interface{}{"type": "array"},
+				map[string]interface{}{"$ref": "#"},
+			},
+		},
+	}
+
+	validator, err = NewValidatorWithConfig(schemas, ValidatorConfig{
+		MaxStackDepth: 3,
+	})
+
+	assert.NoError(t, err)
+
+	_, err = validator.ValidateBatch([]interface{}{
+		[]interface{}{},
+		nil,
+	}, 2)
+
+	assert.Equal(t, ErrStackOverflow, err)
+}
+
+func TestValidatorEpsilon(t *testing.T) {
+	schemas := []interface{}{
+		map[string]interface{}{
+			"multipleOf": float64(2),
+		},
+	}
+
+	loose, err := NewValidatorWithConfig(schemas, ValidatorConfig{
+		Epsilon: 0.1,
+	})
+
+	assert.NoError(t, err)
+
+	strict, err := NewValidatorWithConfig(schemas, ValidatorConfig{
+		Epsilon: -1,
+	})
+
+	assert.NoError(t, err)
+
+	// each validator uses only its own epsilon
+	result, err := loose.Validate(4.05)
+	assert.NoError(t, err)
+	assert.True(t, result.IsValid())
+
+	result, err = strict.Validate(4.05)
+	assert.NoError(t, err)
+	assert.False(t, result.IsValid())
+
+	// epsilon does not apply to any other keyword
+	schemas = []interface{}{
+		map[string]interface{}{
+			"exclusiveMaximum": float64(4),
+			"type":             "integer",
+		},
+	}
+
+	loose, err = NewValidatorWithConfig(schemas, ValidatorConfig{
+		Epsilon: 0.1,
+	})
+
+	assert.NoError(t, err)
+
+	result, err = loose.Validate(3.95)
+	assert.NoError(t, err)
+	assert.Equal(t, []ValidationError{
+		ValidationError{
+			InstancePath: jsonpointer.Ptr{Tokens: []string{}},
+			SchemaPath:   jsonpointer.Ptr{Tokens: []string{"type"}},
+		},
+	}, result.Errors)
+}
+
+func TestValidatorDefaultEpsilon(t *testing.T) {
+	schemas := []interface{}{
+		map[string]interface{}{
+			"multipleOf": 0.1,
+		},
+	}
+
+	// leaving Epsilon as zero uses the default tolerance
+	validator, err := NewValidatorWithConfig(schemas, ValidatorConfig{MaxErrors: 5})
+	assert.NoError(t, err)
+
+	result, err := validator.Validate(0.3)
+	assert.NoError(t, err)
+	assert.True(t, result.IsValid())
+
+	// which comes from the deprecated package-level Epsilon
+	defer func(epsilon float64) { Epsilon = epsilon }(Epsilon)
+	Epsilon = 0.5
+
+	validator, err = NewValidatorWithConfig(schemas, ValidatorConfig{})
+	assert.NoError(t, err)
+
+	result, err = validator.Validate(0.34)
+	assert.NoError(t, err)
+	assert.True(t, result.IsValid())
+
+	validator, err = NewValidatorWithConfig(schemas, ValidatorConfig{Epsilon: -1})
+	assert.NoError(t, err)
+
+	result, err = validator.Validate(0.3)
+	assert.NoError(t, err)
+	assert.False(t, result.IsValid())
+}
+
+func TestValidatorIntegerEpsilon(t *testing.T) {
+	schemas := []interface{}{
+		map[string]interface{}{
+			"type": "integer",
+		},
+	}
+
+	loose, err := NewValidatorWithConfig(schemas, ValidatorConfig{
+		IntegerEpsilon: 1e-6,
+	})
+
+	assert.NoError(t, err)
+
+	strict, err := NewValidator(schemas)
+	assert.NoError(t, err)
+
+	// computed at run time, so that floating-point error is introduced
+	tenth := 0.1
+	almostThree := tenth * 3 * 10
+
+	result, err := loose.Validate(almostThree)
+	assert.NoError(t, err)
+	assert.True(t, result.IsValid())
+
+	result, err = loose.Validate(3.001)
+	assert.NoError(t, err)
+	assert.False(t, result.IsValid())
+
+	result, err = strict.Validate(almostThree)
+	assert.NoError(t, err)
+	assert.False(t, result.IsValid())
+
+	// integer epsilon also applies when checking that keywords are integers
+	schemas = []interface{}{
+		map[string]interface{}{
+			"maxLength": 2.9999999,
+		},
+	}
+
+	_, err = NewValidatorWithConfig(schemas, ValidatorConfig{IntegerEpsilon: 1e-6})
+	assert.NoError(t, err)
+
+	_, err = NewValidator(schemas)
+	assert.Equal(t, ErrInvalidSchema, err)
+}
+
+func TestValidatorExactDecimal(t *testing.T) {
+	schemas := []interface{}{
+		map[string]interface{}{
+			"multipleOf": 0.01,
+		},
+	}
+
+	float, err := NewValidatorWithConfig(schemas, ValidatorConfig{
+		Epsilon: -1,
+	})
+
+	assert.NoError(t, err)
+
+	decimal, err := NewValidatorWithConfig(schemas, ValidatorConfig{
+		ExactDecimal: true,
+	})
+
+	assert.NoError(t, err)
+
+	// without any tolerance, floating-point remainders get this case wrong
+	result, err := float.Validate(19.99)
+	assert.NoError(t, err)
+	assert.False(t, result.IsValid())
+
+	testCases := []struct {
+		instance float64
+		valid    bool
+	}{
+		{19.99, true},
+		{-19.99, true},
+		{0, true},
+		{1e21, true},
+		{0.015, false},
+		{19.999, false},
+	}
+
+	for _, tt := range testCases {
+		result, err := decimal.Validate(tt.instance)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.valid, result.IsValid(), "%v", tt.instance)
+	}
+
+	// numbers too large to be a float64 are infinite, and so not multiples
+	for _, instance := range []interface{}{json.Number("1e400"), json.Number("-1e400")} {
+		for _, validator := range []Validator{float, decimal} {
+			result, err := validator.Validate(instance)
+			assert.NoError(t, err)
+			assert.False(t, result.IsValid(), "%v", instance)
+		}
+	}
+}
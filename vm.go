@@ -1,241 +1,504 @@
 package jsonschema
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
-	"math"
 	"net/url"
 	"reflect"
+	"sort"
 	"strconv"
 	"unicode/utf8"
 
 	jsonpointer "github.com/json-schema-spec/json-pointer-go"
 )
 
-// Epsilon is the value used to determine if a floating-point value is "close
-// enough" to be considered an integer.
-//
-// You may adjust this value if you require many significant digits before
-// considering a number to be essentially integral, but consider that
-// floating-point arithemtic has different precision based on the range of
-// numbers being represented.
-var Epsilon = 1e-3
-
 var errMaxErrors = errors.New("internal error for maximum errors")
 
+// errInvalid is returned by reportError when the vm is only deciding whether
+// an instance is valid, to stop evaluation at the first error.
+var errInvalid = errors.New("internal error for invalid instance")
+
+// ctxCheckInterval is how many schema evaluations the vm performs between
+// checks of whether its context is done.
+const ctxCheckInterval = 64
+
 type vm struct {
-	// registry holds an arena of schemas
+	// ctx is the context evaluation is performed under
+	ctx context.Context
+
+	// done is ctx.Done(), or nil if ctx can never be done
+	done <-chan struct{}
+
+	// ticks counts evaluations since evaluation began, and is used to decide
+	// when to next check done
+	ticks int
+
+	// registry maps URIs to the schemas in its arena
 	registry registry
 
+	// program is the compiled form of the registry's arena
+	program *program
+
 	// stack holds state used for error-message generation
 	stack stack
 
+	// pseudo is how many pseudoExec calls are in progress; errors reported
+	// while it is non-zero are only noted, not produced
+	pseudo int
+
+	// validOnly is whether the vm is only deciding whether an instance is
+	// valid, in which case no errors are produced and evaluation stops at the
+	// first one
+	validOnly bool
+
+	// keys holds the sorted keys of the objects being evaluated, one after
+	// another, so that sorting them needs no allocation once warmed up
+	keys []string
+
 	// errors holds all the errors to be produced
 	errors vmErrors
 
+	// epsilon is the tolerance used in evaluating multipleOf
+	epsilon float64
+
+	// integerEpsilon is the tolerance used in deciding if a number is an integer
+	integerEpsilon float64
+
+	// exactDecimal is whether to evaluate multipleOf with decimal arithmetic
+	exactDecimal bool
+
 	// maxStackDepth is the most number of $ref-s that can be followed at once
+	// without descending into the instance
 	maxStackDepth int
 
 	// maxErrors is the most number of errors that can be reported
 	maxErrors int
+
+	// countAllErrors is whether to keep counting errors past maxErrors
+	countAllErrors bool
+
+	// deduplicateErrors is whether to discard errors identical to ones already
+	// reported
+	deduplicateErrors bool
+
+	// memoize is whether to remember the outcomes of pseudoExec
+	memoize bool
+
+	// memo holds the outcomes of pseudoExec for objects and arrays, when
+	// memoizing
+	memo map[memoKey]bool
+
+	// maxInstanceDepth is the deepest the vm may descend into an instance
+	maxInstanceDepth int
+
+	// maxNodes is the most number of instance values that may be visited
+	maxNodes int
+
+	// maxFuel is the most number of keywords that may be evaluated
+	maxFuel int
+
+	// maxStringLength is the longest, in bytes, an instance string may be
+	maxStringLength int
+
+	// maxArrayLength is the most number of elements an instance array may have
+	maxArrayLength int
+
+	// nodes is the number of instance values visited so far
+	nodes int
+
+	// fuel is the number of keywords evaluated so far
+	fuel int
 }
 
 type vmErrors struct {
-	hasErrors bool
-	errors    []ValidationError
+	hasErrors  bool
+	overflowed bool
+	count      int
+	errors     []ValidationError
+
+	// seen holds a key for each error reported, and is used only when
+	// deduplicating errors
+	seen map[string]struct{}
 }
 
 // stack keeps track of where we are in an instance and schema. It is meant to
 // be used in cohort with the ordinary function call stack in order to produce
 // error messages.
+//
+// JSON Pointers are only constructed from the stack when an error is reported,
+// so that evaluating a valid instance does not allocate.
 type stack struct {
 	// instance is a stack of tokens into the instance, meant to construct a JSON
 	// Pointer.
-	instance []string
+	instance []token
 
-	// schema is a stack of stacks of tokens into the schema, meant to construct a
-	// JSON Pointer. Each schema gets its own stack; because of cross-references,
-	// there may be many schemas in use.
+	// schema is a stack of schemas in use. Because of cross-references, there
+	// may be many schemas in use.
 	schemas []schemaStack
+
+	// schemaTokens holds the tokens into each schema in use, one after another,
+	// meant to construct JSON Pointers. Each schemaStack owns the tokens from
+	// its start onwards.
+	schemaTokens []token
 }
 
-// schemaStack keeps track of where we are in a schema, and which schema we are
-// in.
+// schemaStack keeps track of which schema we are in, and where its tokens
+// begin.
 type schemaStack struct {
 	// id is the (non-relative) ID of the schema
 	id url.URL
 
-	// tokens is a stack of tokens into the schema, meant to construct a JSON
-	// Pointer.
-	tokens []string
+	// start is the index in schemaTokens of the first token into the schema
+	start int
+
+	// depth is the depth within the instance at which the schema came into use
+	depth int
+
+	// hops is how many schemas, including this one, came into use at that
+	// depth, one after another
+	hops int
+}
+
+// token is a token of a JSON Pointer. Array indices are kept as numbers, and
+// only formatted as strings if an error is reported.
+type token struct {
+	key     string
+	index   int
+	isIndex bool
 }
 
-func newVM(registry registry, maxStackDepth, maxErrors int) vm {
+func (t token) String() string {
+	if t.isIndex {
+		return strconv.Itoa(t.index)
+	}
+
+	return t.key
+}
+
+// formatTokens returns the strings of the given tokens.
+func formatTokens(tokens []token) []string {
+	strs := make([]string, len(tokens))
+	for i, t := range tokens {
+		strs[i] = t.String()
+	}
+
+	return strs
+}
+
+func newVM(ctx context.Context, registry registry, program *program, config ValidatorConfig) vm {
 	return vm{
+		ctx:      ctx,
+		done:     ctx.Done(),
 		registry: registry,
+		program:  program,
 		stack: stack{
-			instance: []string{},
-			schemas:  []schemaStack{},
+			instance:     []token{},
+			schemas:      []schemaStack{},
+			schemaTokens: []token{},
 		},
 		errors: vmErrors{
 			hasErrors: false,
 			errors:    []ValidationError{},
 		},
-		maxStackDepth: maxStackDepth,
-		maxErrors:     maxErrors,
+		epsilon:           config.Epsilon,
+		integerEpsilon:    config.IntegerEpsilon,
+		exactDecimal:      config.ExactDecimal,
+		maxStackDepth:     config.MaxStackDepth,
+		maxErrors:         config.MaxErrors,
+		countAllErrors:    config.CountAllErrors,
+		deduplicateErrors: config.DeduplicateErrors,
+		memoize:           config.Memoize,
+		maxInstanceDepth:  config.MaxInstanceDepth,
+		maxNodes:          config.MaxNodes,
+		maxFuel:           config.MaxFuel,
+		maxStringLength:   config.MaxStringLength,
+		maxArrayLength:    config.MaxArrayLength,
+	}
+}
+
+// reset prepares the vm to evaluate another instance under the given context.
+// The storage already allocated for the vm's stacks is kept for reuse.
+func (vm *vm) reset(ctx context.Context) {
+	vm.ctx = ctx
+	vm.done = ctx.Done()
+	vm.ticks = 0
+	vm.nodes = 0
+	vm.fuel = 0
+	vm.pseudo = 0
+	vm.validOnly = false
+	vm.keys = vm.keys[:0]
+	for key := range vm.memo {
+		delete(vm.memo, key)
+	}
+	vm.stack.instance = vm.stack.instance[:0]
+	vm.stack.schemas = vm.stack.schemas[:0]
+	vm.stack.schemaTokens = vm.stack.schemaTokens[:0]
+	vm.errors = vmErrors{
+		hasErrors: false,
+		errors:    []ValidationError{},
 	}
 }
 
 func (vm *vm) ValidationResult() ValidationResult {
 	return ValidationResult{
-		Errors: vm.errors.errors,
+		Errors:     vm.errors.errors,
+		Overflowed: vm.errors.overflowed,
+		ErrorCount: vm.errors.count,
 	}
 }
 
 func (vm *vm) Exec(uri url.URL, instance interface{}) error {
-	schema, ok := vm.registry.Get(uri)
+	if err := vm.ctx.Err(); err != nil {
+		return err
+	}
+
+	index, ok := vm.registry.schemas[uri]
 	if !ok {
 		return ErrNoSuchSchema
 	}
 
-	fragPtr, err := jsonpointer.New(uri.Fragment)
-	if err != nil {
+	var fragTokens []string
+	if uri.Fragment != "" {
+		fragPtr, err := jsonpointer.New(uri.Fragment)
+		if err != nil {
+			return err
+		}
+
+		fragTokens = fragPtr.Tokens
+	}
+
+	if err := vm.visitNode(); err != nil {
+		return err
+	}
+
+	if err := vm.checkLength(instance); err != nil {
 		return err
 	}
 
-	vm.pushNewSchema(uri, fragPtr.Tokens)
-	err = vm.execSchema(schema, instance)
+	vm.pushNewSchema(uri, fragTokens)
+	err := vm.execSchema(index, instance)
 	if err == errMaxErrors {
 		// not a real error -- just an internal flag to quit early
+		vm.errors.overflowed = true
+		return nil
+	}
+
+	if err == errInvalid {
+		// likewise, but for when only validity is being decided
+		vm.errors.hasErrors = true
 		return nil
 	}
 
 	return err
 }
 
-func (vm *vm) execSchema(schema schema, instance interface{}) error {
-	if schema.Bool.IsSet {
-		if !schema.Bool.Value {
-			if err := vm.reportError(); err != nil {
+// IsValid is like Exec, but only decides whether the instance is valid. It
+// stops at the first error, and produces no errors.
+func (vm *vm) IsValid(uri url.URL, instance interface{}) (bool, error) {
+	vm.validOnly = true
+	if err := vm.Exec(uri, instance); err != nil {
+		return false, err
+	}
+
+	return !vm.errors.hasErrors, nil
+}
+
+// execSchema evaluates the instance against the node at the given index of the
+// vm's program.
+func (vm *vm) execSchema(index int, instance interface{}) error {
+	if err := vm.checkContext(); err != nil {
+		return err
+	}
+
+	node := &vm.program.nodes[index]
+	schema := node.schema
+
+	for _, op := range node.common {
+		if err := vm.execCommon(op, schema, instance); err != nil {
+			return err
+		}
+	}
+
+	switch val := instance.(type) {
+	case nil:
+		for _, op := range node.typed[instanceKindNull] {
+			if err := vm.execType(op); err != nil {
+				return err
+			}
+		}
+	case bool:
+		for _, op := range node.typed[instanceKindBoolean] {
+			if err := vm.execType(op); err != nil {
+				return err
+			}
+		}
+	case float64:
+		for _, op := range node.typed[instanceKindNumber] {
+			if err := vm.execNumber(op, schema, val); err != nil {
 				return err
 			}
 		}
+	case json.Number:
+		f, ok := numberFloat(val)
+		if !ok {
+			panic("unexpected non-json input")
+		}
 
-		return nil
-	}
+		for _, op := range node.typed[instanceKindNumber] {
+			if err := vm.execNumber(op, schema, f); err != nil {
+				return err
+			}
+		}
+	case string:
+		return vm.execStringOps(node, val)
+	case []interface{}:
+		for _, op := range node.typed[instanceKindArray] {
+			if err := vm.execArray(op, schema, val); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		start := len(vm.keys)
+		if node.sortKeys {
+			for key := range val {
+				vm.keys = append(vm.keys, key)
+			}
 
-	if schema.Ref.IsSet {
-		if len(vm.stack.schemas) == vm.maxStackDepth {
-			return ErrStackOverflow
+			sort.Strings(vm.keys[start:])
+		}
+
+		keys := vm.keys[start:]
+		for _, op := range node.typed[instanceKindObject] {
+			if err := vm.execObject(op, schema, val, keys); err != nil {
+				return err
+			}
 		}
 
-		refSchema := vm.registry.GetIndex(schema.Ref.Schema)
+		vm.keys = vm.keys[:start]
+	default:
+		// TODO a better error here
+		panic("unexpected non-json input")
+	}
 
-		schemaTokens := make([]string, len(schema.Ref.Ptr.Tokens))
-		copy(schemaTokens, schema.Ref.Ptr.Tokens)
+	return nil
+}
 
-		vm.pushNewSchema(schema.Ref.BaseURI, schemaTokens)
-		if err := vm.execSchema(refSchema, instance); err != nil {
+// execStringOps evaluates the instructions of node which apply only to
+// strings.
+func (vm *vm) execStringOps(node *node, val string) error {
+	for _, op := range node.typed[instanceKindString] {
+		if err := vm.execString(op, node.schema, val); err != nil {
 			return err
 		}
-		vm.popSchema()
 	}
 
-	if schema.Not.IsSet {
-		notSchema := vm.registry.GetIndex(schema.Not.Schema)
-		notErrors, err := vm.pseudoExec(notSchema, instance)
+	return nil
+}
+
+// execPropertyName evaluates the key of an object member against the node at
+// the given index. Most schemas for property names only have keywords which
+// apply to strings in particular; for those, the key is not converted into an
+// interface{}, as doing so would allocate.
+func (vm *vm) execPropertyName(index int, key string) error {
+	node := &vm.program.nodes[index]
+	if len(node.common) > 0 {
+		return vm.execSchema(index, key)
+	}
+
+	if err := vm.checkContext(); err != nil {
+		return err
+	}
+
+	return vm.execStringOps(node, key)
+}
+
+// execCommon evaluates an instruction which applies to every kind of instance.
+func (vm *vm) execCommon(op opcode, schema *schema, instance interface{}) error {
+	if err := vm.useFuel(); err != nil {
+		return err
+	}
+
+	switch op {
+	case opTrue:
+		return nil
+	case opFalse:
+		return vm.reportError()
+	case opRef:
+		top := vm.stack.schemas[len(vm.stack.schemas)-1]
+		if top.depth == len(vm.stack.instance) && top.hops == vm.maxStackDepth {
+			return ErrStackOverflow
+		}
+
+		vm.pushNewSchema(schema.Ref.BaseURI, schema.Ref.Ptr.Tokens)
+		if err := vm.execSchema(schema.Ref.Schema, instance); err != nil {
+			return err
+		}
+		vm.popSchema()
+	case opNot:
+		notErrors, err := vm.pseudoExec(schema.Not.Schema, instance)
 		if err != nil {
 			return err
 		}
 
 		if !notErrors {
-			vm.pushSchemaToken("not")
-			if err := vm.reportError(); err != nil {
-				return err
-			}
-			vm.popSchemaToken()
+			return vm.reportKeywordError("not")
 		}
-	}
-
-	if schema.If.IsSet {
-		ifSchema := vm.registry.GetIndex(schema.If.Schema)
-		ifErrors, err := vm.pseudoExec(ifSchema, instance)
+	case opIf:
+		ifErrors, err := vm.pseudoExec(schema.If.Schema, instance)
 		if err != nil {
 			return err
 		}
 
 		if !ifErrors {
 			if schema.Then.IsSet {
-				thenSchema := vm.registry.GetIndex(schema.Then.Schema)
+				if err := vm.useFuel(); err != nil {
+					return err
+				}
 
 				vm.pushSchemaToken("then")
-				if err := vm.execSchema(thenSchema, instance); err != nil {
+				if err := vm.execSchema(schema.Then.Schema, instance); err != nil {
 					return err
 				}
 				vm.popSchemaToken()
 			}
 		} else {
 			if schema.Else.IsSet {
-				elseSchema := vm.registry.GetIndex(schema.Else.Schema)
+				if err := vm.useFuel(); err != nil {
+					return err
+				}
 
 				vm.pushSchemaToken("else")
-				if err := vm.execSchema(elseSchema, instance); err != nil {
+				if err := vm.execSchema(schema.Else.Schema, instance); err != nil {
 					return err
 				}
 				vm.popSchemaToken()
 			}
 		}
-	}
-
-	if schema.Const.IsSet {
-		if !reflect.DeepEqual(instance, schema.Const.Value) {
-			vm.pushSchemaToken("const")
-			if err := vm.reportError(); err != nil {
-				return err
-			}
-			vm.popSchemaToken()
-		}
-	}
-
-	if schema.Enum.IsSet {
-		enumOk := false
-		for _, value := range schema.Enum.Values {
-			if reflect.DeepEqual(instance, value) {
-				enumOk = true
-				break
-			}
+	case opConst:
+		if !equalJSON(instance, schema.Const.Value) {
+			return vm.reportKeywordError("const")
 		}
-
-		if !enumOk {
-			vm.pushSchemaToken("enum")
-			if err := vm.reportError(); err != nil {
-				return err
-			}
-			vm.popSchemaToken()
+	case opEnum:
+		if !schema.Enum.contains(instance) {
+			return vm.reportKeywordError("enum")
 		}
-	}
-
-	if schema.AllOf.IsSet {
+	case opAllOf:
 		vm.pushSchemaToken("allOf")
 
 		for i, index := range schema.AllOf.Schemas {
-			allOfSchema := vm.registry.GetIndex(index)
-			token := strconv.FormatInt(int64(i), 10)
-
-			vm.pushSchemaToken(token)
-			if err := vm.execSchema(allOfSchema, instance); err != nil {
+			vm.pushSchemaIndex(i)
+			if err := vm.execSchema(index, instance); err != nil {
 				return err
 			}
 			vm.popSchemaToken()
 		}
 
 		vm.popSchemaToken()
-	}
-
-	if schema.AnyOf.IsSet {
+	case opAnyOf:
 		anyOfOk := false
 		for _, index := range schema.AnyOf.Schemas {
-			anyOfSchema := vm.registry.GetIndex(index)
-			anyOfErrors, err := vm.pseudoExec(anyOfSchema, instance)
+			anyOfErrors, err := vm.pseudoExec(index, instance)
 			if err != nil {
 				return err
 			}
@@ -247,19 +510,12 @@ func (vm *vm) execSchema(schema schema, instance interface{}) error {
 		}
 
 		if !anyOfOk {
-			vm.pushSchemaToken("anyOf")
-			if err := vm.reportError(); err != nil {
-				return err
-			}
-			vm.popSchemaToken()
+			return vm.reportKeywordError("anyOf")
 		}
-	}
-
-	if schema.OneOf.IsSet {
+	case opOneOf:
 		oneOfOk := false
 		for _, index := range schema.OneOf.Schemas {
-			oneOfSchema := vm.registry.GetIndex(index)
-			oneOfErrors, err := vm.pseudoExec(oneOfSchema, instance)
+			oneOfErrors, err := vm.pseudoExec(index, instance)
 			if err != nil {
 				return err
 			}
@@ -275,468 +531,664 @@ func (vm *vm) execSchema(schema schema, instance interface{}) error {
 		}
 
 		if !oneOfOk {
-			vm.pushSchemaToken("oneOf")
-			if err := vm.reportError(); err != nil {
-				return err
-			}
-			vm.popSchemaToken()
+			return vm.reportKeywordError("oneOf")
 		}
 	}
 
-	switch val := instance.(type) {
-	case nil:
-		if schema.Type.IsSet && !schema.Type.contains(jsonTypeNull) {
-			vm.pushSchemaToken("type")
-			if err := vm.reportError(); err != nil {
-				return err
-			}
-			vm.popSchemaToken()
+	return nil
+}
+
+// execType evaluates an instruction for "type" whose outcome was decided when
+// the schema was compiled.
+func (vm *vm) execType(op opcode) error {
+	if err := vm.useFuel(); err != nil {
+		return err
+	}
+
+	if op == opTypeReject {
+		return vm.reportKeywordError("type")
+	}
+
+	return nil
+}
+
+// execNumber evaluates an instruction which applies only to numbers.
+func (vm *vm) execNumber(op opcode, schema *schema, val float64) error {
+	switch op {
+	case opTypeAccept, opTypeReject:
+		return vm.execType(op)
+	}
+
+	if err := vm.useFuel(); err != nil {
+		return err
+	}
+
+	switch op {
+	case opTypeInteger:
+		if !isInteger(val, vm.integerEpsilon) {
+			return vm.reportKeywordError("type")
 		}
-	case bool:
-		if schema.Type.IsSet && !schema.Type.contains(jsonTypeBoolean) {
-			vm.pushSchemaToken("type")
-			if err := vm.reportError(); err != nil {
-				return err
-			}
-			vm.popSchemaToken()
+	case opMultipleOf:
+		if !vm.isMultipleOf(val, schema.MultipleOf) {
+			return vm.reportKeywordError("multipleOf")
 		}
-	case float64:
-		if schema.Type.IsSet {
-			typeOk := false
-			if schema.Type.contains(jsonTypeInteger) {
-				typeOk = val == math.Round(val)
-			}
+	case opMaximum:
+		if val > schema.Maximum.Value {
+			return vm.reportKeywordError("maximum")
+		}
+	case opMinimum:
+		if val < schema.Minimum.Value {
+			return vm.reportKeywordError("minimum")
+		}
+	case opExclusiveMaximum:
+		if val >= schema.ExclusiveMaximum.Value {
+			return vm.reportKeywordError("exclusiveMaximum")
+		}
+	case opExclusiveMinimum:
+		if val <= schema.ExclusiveMinimum.Value {
+			return vm.reportKeywordError("exclusiveMinimum")
+		}
+	}
 
-			if !typeOk && !schema.Type.contains(jsonTypeNumber) {
-				vm.pushSchemaToken("type")
-				if err := vm.reportError(); err != nil {
-					return err
-				}
-				vm.popSchemaToken()
-			}
+	return nil
+}
+
+// execString evaluates an instruction which applies only to strings.
+func (vm *vm) execString(op opcode, schema *schema, val string) error {
+	switch op {
+	case opTypeAccept, opTypeReject:
+		return vm.execType(op)
+	}
+
+	if err := vm.useFuel(); err != nil {
+		return err
+	}
+
+	switch op {
+	case opMaxLength:
+		if utf8.RuneCountInString(val) > schema.MaxLength.Value {
+			return vm.reportKeywordError("maxLength")
 		}
+	case opMinLength:
+		if utf8.RuneCountInString(val) < schema.MinLength.Value {
+			return vm.reportKeywordError("minLength")
+		}
+	case opPattern:
+		if !schema.Pattern.Value.MatchString(val) {
+			return vm.reportKeywordError("pattern")
+		}
+	}
 
-		if schema.MultipleOf.IsSet {
-			if math.Abs(math.Mod(val, schema.MultipleOf.Value)) > Epsilon {
-				vm.pushSchemaToken("multipleOf")
-				if err := vm.reportError(); err != nil {
-					return err
-				}
-				vm.popSchemaToken()
-			}
+	return nil
+}
+
+// execArray evaluates an instruction which applies only to arrays.
+func (vm *vm) execArray(op opcode, schema *schema, val []interface{}) error {
+	switch op {
+	case opTypeAccept, opTypeReject:
+		return vm.execType(op)
+	}
+
+	if err := vm.useFuel(); err != nil {
+		return err
+	}
+
+	switch op {
+	case opMaxItems:
+		if len(val) > schema.MaxItems.Value {
+			return vm.reportKeywordError("maxItems")
+		}
+	case opMinItems:
+		if len(val) < schema.MinItems.Value {
+			return vm.reportKeywordError("minItems")
+		}
+	case opUniqueItems:
+		i, j, ok, err := vm.findDuplicate(val)
+		if err != nil {
+			return err
 		}
 
-		if schema.Maximum.IsSet {
-			if val > schema.Maximum.Value {
-				vm.pushSchemaToken("maximum")
-				if err := vm.reportError(); err != nil {
-					return err
-				}
-				vm.popSchemaToken()
+		if ok {
+			vm.pushSchemaToken("uniqueItems")
+			if err := vm.reportDuplicates(i, j); err != nil {
+				return err
 			}
+			vm.popSchemaToken()
 		}
+	case opContains:
+		containsOk := false
+		for i, elem := range val {
+			if err := vm.pushInstanceIndex(i, elem); err != nil {
+				return err
+			}
+			containsErrors, err := vm.pseudoExec(schema.Contains.Schema, elem)
+			if err != nil {
+				return err
+			}
+			vm.popInstanceToken()
 
-		if schema.Minimum.IsSet {
-			if val < schema.Minimum.Value {
-				vm.pushSchemaToken("minimum")
-				if err := vm.reportError(); err != nil {
-					return err
-				}
-				vm.popSchemaToken()
+			if !containsErrors {
+				containsOk = true
+				break
 			}
 		}
 
-		if schema.ExclusiveMaximum.IsSet {
-			if val > schema.ExclusiveMaximum.Value-Epsilon {
-				vm.pushSchemaToken("exclusiveMaximum")
-				if err := vm.reportError(); err != nil {
-					return err
-				}
-				vm.popSchemaToken()
-			}
+		if !containsOk {
+			return vm.reportKeywordError("contains")
 		}
+	case opItems:
+		vm.pushSchemaToken("items")
 
-		if schema.ExclusiveMinimum.IsSet {
-			if val < schema.ExclusiveMinimum.Value+Epsilon {
-				vm.pushSchemaToken("exclusiveMinimum")
-				if err := vm.reportError(); err != nil {
-					return err
-				}
-				vm.popSchemaToken()
+		itemSchema := schema.Items.Schemas[0]
+		for i, elem := range val {
+			if err := vm.pushInstanceIndex(i, elem); err != nil {
+				return err
 			}
-		}
-	case string:
-		if schema.Type.IsSet && !schema.Type.contains(jsonTypeString) {
-			vm.pushSchemaToken("type")
-			if err := vm.reportError(); err != nil {
+			if err := vm.execSchema(itemSchema, elem); err != nil {
 				return err
 			}
-			vm.popSchemaToken()
+			vm.popInstanceToken()
 		}
 
-		if schema.MaxLength.IsSet {
-			if utf8.RuneCountInString(val) > schema.MaxLength.Value {
-				vm.pushSchemaToken("maxLength")
-				if err := vm.reportError(); err != nil {
-					return err
-				}
-				vm.popSchemaToken()
-			}
-		}
-
-		if schema.MinLength.IsSet {
-			if utf8.RuneCountInString(val) < schema.MinLength.Value {
-				vm.pushSchemaToken("minLength")
-				if err := vm.reportError(); err != nil {
-					return err
-				}
-				vm.popSchemaToken()
-			}
-		}
+		vm.popSchemaToken()
+	case opTupleItems:
+		vm.pushSchemaToken("items")
 
-		if schema.Pattern.IsSet {
-			if !schema.Pattern.Value.MatchString(val) {
-				vm.pushSchemaToken("pattern")
-				if err := vm.reportError(); err != nil {
-					return err
-				}
-				vm.popSchemaToken()
+		for i := 0; i < len(schema.Items.Schemas) && i < len(val); i++ {
+			if err := vm.pushInstanceIndex(i, val[i]); err != nil {
+				return err
 			}
-		}
-	case []interface{}:
-		if schema.Type.IsSet && !schema.Type.contains(jsonTypeArray) {
-			vm.pushSchemaToken("type")
-			if err := vm.reportError(); err != nil {
+			vm.pushSchemaIndex(i)
+			if err := vm.execSchema(schema.Items.Schemas[i], val[i]); err != nil {
 				return err
 			}
+			vm.popInstanceToken()
 			vm.popSchemaToken()
 		}
 
-		if schema.MaxItems.IsSet {
-			if len(val) > schema.MaxItems.Value {
-				vm.pushSchemaToken("maxItems")
-				if err := vm.reportError(); err != nil {
-					return err
-				}
-				vm.popSchemaToken()
-			}
-		}
+		vm.popSchemaToken()
+	case opAdditionalItems:
+		vm.pushSchemaToken("additionalItems")
 
-		if schema.MinItems.IsSet {
-			if len(val) < schema.MinItems.Value {
-				vm.pushSchemaToken("minItems")
-				if err := vm.reportError(); err != nil {
-					return err
-				}
-				vm.popSchemaToken()
+		for i := len(schema.Items.Schemas); i < len(val); i++ {
+			if err := vm.pushInstanceIndex(i, val[i]); err != nil {
+				return err
+			}
+			if err := vm.execSchema(schema.AdditionalItems.Schema, val[i]); err != nil {
+				return err
 			}
+			vm.popInstanceToken()
 		}
 
-		if schema.UniqueItems.IsSet && schema.UniqueItems.Value {
-		loop:
-			for i := 0; i < len(val); i++ {
-				for j := i + 1; j < len(val); j++ {
-					if reflect.DeepEqual(val[i], val[j]) {
-						vm.pushSchemaToken("uniqueItems")
-						if err := vm.reportError(); err != nil {
-							return err
-						}
-						vm.popSchemaToken()
+		vm.popSchemaToken()
+	}
 
-						break loop
-					}
-				}
-			}
-		}
+	return nil
+}
 
-		if schema.Contains.IsSet {
-			containsOk := false
-			for _, elem := range val {
-				containsSchema := vm.registry.GetIndex(schema.Contains.Schema)
-				containsErrors, err := vm.pseudoExec(containsSchema, elem)
-				if err != nil {
+// execObject evaluates an instruction which applies only to objects. keys holds
+// the sorted keys of val, if the node has instructions which need them.
+func (vm *vm) execObject(op opcode, schema *schema, val map[string]interface{}, keys []string) error {
+	switch op {
+	case opTypeAccept, opTypeReject:
+		return vm.execType(op)
+	case opProperties:
+		// fuel is used for each subschema applied, rather than for the keyword
+		for _, key := range keys {
+			value := val[key]
+
+			err := schema.propertySchemas(key, func(keyword, name string, index int) error {
+				if err := vm.useFuel(); err != nil {
 					return err
 				}
 
-				if !containsErrors {
-					containsOk = true
-					break
+				vm.pushSchemaToken(keyword)
+				if keyword != "additionalProperties" {
+					vm.pushSchemaToken(name)
 				}
-			}
-
-			if !containsOk {
-				vm.pushSchemaToken("contains")
-				if err := vm.reportError(); err != nil {
+				if err := vm.pushInstanceToken(key, value); err != nil {
 					return err
 				}
-				vm.popSchemaToken()
-			}
-		}
-
-		if schema.Items.IsSet {
-			if schema.Items.IsSingle {
-				vm.pushSchemaToken("items")
-
-				itemSchema := vm.registry.GetIndex(schema.Items.Schemas[0])
-				for i, elem := range val {
-					vm.pushInstanceToken(strconv.FormatInt(int64(i), 10))
-					if err := vm.execSchema(itemSchema, elem); err != nil {
-						return err
-					}
-					vm.popInstanceToken()
+				if err := vm.execSchema(index, value); err != nil {
+					return err
 				}
-				vm.popSchemaToken()
-			} else {
-				vm.pushSchemaToken("items")
-				for i := 0; i < len(schema.Items.Schemas) && i < len(val); i++ {
-					itemSchema := vm.registry.GetIndex(schema.Items.Schemas[i])
-					token := strconv.FormatInt(int64(i), 10)
-
-					vm.pushInstanceToken(token)
-					vm.pushSchemaToken(token)
-					if err := vm.execSchema(itemSchema, val[i]); err != nil {
-						return err
-					}
-					vm.popInstanceToken()
+				vm.popInstanceToken()
+				if keyword != "additionalProperties" {
 					vm.popSchemaToken()
 				}
 				vm.popSchemaToken()
 
-				if schema.AdditionalItems.IsSet {
-					vm.pushSchemaToken("additionalItems")
-
-					additionalItemSchema := vm.registry.GetIndex(schema.AdditionalItems.Schema)
-					for i := len(schema.Items.Schemas); i < len(val); i++ {
-						token := strconv.FormatInt(int64(i), 10)
+				return nil
+			})
 
-						vm.pushInstanceToken(token)
-						if err := vm.execSchema(additionalItemSchema, val[i]); err != nil {
-							return err
-						}
-						vm.popInstanceToken()
-					}
-					vm.popSchemaToken()
-				}
-			}
-		}
-	case map[string]interface{}:
-		if schema.Type.IsSet && !schema.Type.contains(jsonTypeObject) {
-			vm.pushSchemaToken("type")
-			if err := vm.reportError(); err != nil {
+			if err != nil {
 				return err
 			}
-			vm.popSchemaToken()
 		}
 
-		if schema.MaxProperties.IsSet {
-			if len(val) > schema.MaxProperties.Value {
-				vm.pushSchemaToken("maxProperties")
-				if err := vm.reportError(); err != nil {
-					return err
-				}
-				vm.popSchemaToken()
-			}
+		return nil
+	}
+
+	if err := vm.useFuel(); err != nil {
+		return err
+	}
+
+	switch op {
+	case opMaxProperties:
+		if len(val) > schema.MaxProperties.Value {
+			return vm.reportKeywordError("maxProperties")
 		}
+	case opMinProperties:
+		if len(val) < schema.MinProperties.Value {
+			return vm.reportKeywordError("minProperties")
+		}
+	case opRequired:
+		vm.pushSchemaToken("required")
 
-		if schema.MinProperties.IsSet {
-			if len(val) < schema.MinProperties.Value {
-				vm.pushSchemaToken("minProperties")
-				if err := vm.reportError(); err != nil {
+		for i, property := range schema.Required.Properties {
+			if _, ok := val[property]; !ok {
+				if err := vm.reportIndexError(i); err != nil {
 					return err
 				}
-				vm.popSchemaToken()
 			}
 		}
 
-		if schema.Required.IsSet {
-			vm.pushSchemaToken("required")
+		vm.popSchemaToken()
+	case opDependencies:
+		vm.pushSchemaToken("dependencies")
+
+		for _, dep := range schema.Dependencies.Deps {
+			vm.pushSchemaToken(dep.Property)
 
-			for i, property := range schema.Required.Properties {
-				if _, ok := val[property]; !ok {
-					vm.pushSchemaToken(strconv.FormatInt(int64(i), 10))
-					if err := vm.reportError(); err != nil {
+			if _, ok := val[dep.Property]; ok {
+				if dep.IsSchema {
+					if err := vm.execSchema(dep.Schema, val); err != nil {
 						return err
 					}
-					vm.popSchemaToken()
+				} else {
+					for i, property := range dep.Properties {
+						if _, ok := val[property]; !ok {
+							if err := vm.reportIndexError(i); err != nil {
+								return err
+							}
+						}
+					}
 				}
 			}
 
 			vm.popSchemaToken()
 		}
 
-		for key, value := range val {
-			isAdditional := true
-
-			if schema.Properties.IsSet {
-				if index, ok := schema.Properties.Schemas[key]; ok {
-					isAdditional = false
-					propertySchema := vm.registry.GetIndex(index)
-
-					vm.pushSchemaToken("properties")
-					vm.pushSchemaToken(key)
-					vm.pushInstanceToken(key)
-					if err := vm.execSchema(propertySchema, value); err != nil {
-						return err
-					}
-					vm.popInstanceToken()
-					vm.popSchemaToken()
-					vm.popSchemaToken()
-				}
+		vm.popSchemaToken()
+	case opPropertyNames:
+		vm.pushSchemaToken("propertyNames")
+
+		for _, key := range keys {
+			// the key is evaluated as a string in its own right, but is not
+			// passed as the value, as converting it to an interface{} would
+			// allocate
+			if err := vm.pushInstanceToken(key, nil); err != nil {
+				return err
 			}
-
-			if schema.PatternProperties.IsSet {
-				for pattern, index := range schema.PatternProperties.Schemas {
-					if pattern.MatchString(key) {
-						isAdditional = false
-						propertySchema := vm.registry.GetIndex(index)
-
-						vm.pushSchemaToken("patternProperties")
-						vm.pushSchemaToken(pattern.String())
-						vm.pushInstanceToken(key)
-						if err := vm.execSchema(propertySchema, value); err != nil {
-							return err
-						}
-						vm.popInstanceToken()
-						vm.popSchemaToken()
-						vm.popSchemaToken()
-					}
-				}
+			if err := vm.checkStringLength(key); err != nil {
+				return err
 			}
-
-			if schema.AdditionalProperties.IsSet && isAdditional {
-				propertySchema := vm.registry.GetIndex(schema.AdditionalProperties.Schema)
-
-				vm.pushSchemaToken("additionalProperties")
-				vm.pushInstanceToken(key)
-				if err := vm.execSchema(propertySchema, value); err != nil {
-					return err
-				}
-				vm.popInstanceToken()
-				vm.popSchemaToken()
+			if err := vm.execPropertyName(schema.PropertyNames.Schema, key); err != nil {
+				return err
 			}
+			vm.popInstanceToken()
 		}
 
-		if schema.Dependencies.IsSet {
-			vm.pushSchemaToken("dependencies")
+		vm.popSchemaToken()
+	}
 
-			for key, dep := range schema.Dependencies.Deps {
-				vm.pushSchemaToken(key)
+	return nil
+}
 
-				if _, ok := val[key]; ok {
-					if dep.IsSchema {
-						propertySchema := vm.registry.GetIndex(dep.Schema)
+// pseudoExec determines whether a given schema accepts an instance, with the
+// guarantee that the vm exits this function in the same state it was in when
+// the function was called.
+//
+// Errors found here are discarded, so they are only noted rather than produced,
+// and do not count towards the maximum number of errors.
+func (vm *vm) pseudoExec(index int, instance interface{}) (bool, error) {
+	if !vm.memoize {
+		return vm.execPseudo(index, instance)
+	}
 
-						if err := vm.execSchema(propertySchema, val); err != nil {
-							return err
-						}
-					} else {
-						for i, property := range dep.Properties {
-							if _, ok := val[property]; !ok {
-								vm.pushSchemaToken(strconv.FormatInt(int64(i), 10))
-								if err := vm.reportError(); err != nil {
-									return err
-								}
-								vm.popSchemaToken()
-							}
-						}
-					}
-				}
+	key, ok := newMemoKey(index, instance)
+	if !ok {
+		return vm.execPseudo(index, instance)
+	}
 
-				vm.popSchemaToken()
-			}
+	if hasErrors, ok := vm.memo[key]; ok {
+		return hasErrors, nil
+	}
 
-			vm.popSchemaToken()
-		}
+	hasErrors, err := vm.execPseudo(index, instance)
+	if err != nil {
+		return false, err
+	}
 
-		if schema.PropertyNames.IsSet {
-			vm.pushSchemaToken("propertyNames")
+	if vm.memo == nil {
+		vm.memo = map[memoKey]bool{}
+	}
 
-			propertyNameSchema := vm.registry.GetIndex(schema.PropertyNames.Schema)
-			for key := range val {
-				vm.pushInstanceToken(key)
-				if err := vm.execSchema(propertyNameSchema, key); err != nil {
-					return err
-				}
-				vm.popInstanceToken()
-			}
+	vm.memo[key] = hasErrors
+	return hasErrors, nil
+}
 
-			vm.popSchemaToken()
+// memoKey identifies the evaluation of a schema against an object or array
+// within an instance.
+type memoKey struct {
+	// index is the index of the schema in the vm's program
+	index int
+
+	// ptr is the address of the map, or of the first element of the slice
+	ptr uintptr
+
+	// len is the length of the slice, or zero for a map
+	len int
+}
+
+// newMemoKey returns the key of evaluating the schema at index against
+// instance, if instance is an object or non-empty array. Other values are
+// cheap enough to evaluate again, and have no identity to tell them apart.
+func newMemoKey(index int, instance interface{}) (memoKey, bool) {
+	switch val := instance.(type) {
+	case map[string]interface{}:
+		return memoKey{index: index, ptr: reflect.ValueOf(val).Pointer()}, true
+	case []interface{}:
+		if len(val) == 0 {
+			return memoKey{}, false
 		}
+
+		return memoKey{index: index, ptr: reflect.ValueOf(val).Pointer(), len: len(val)}, true
 	default:
-		// TODO a better error here
-		panic("unexpected non-json input")
+		return memoKey{}, false
 	}
-
-	return nil
 }
 
-// pseudoExec determines whether a given schema accepts an instance, with the
-// guarantee that the vm exits this function in the same state it was in when
-// the function was called.
-func (vm *vm) pseudoExec(schema schema, instance interface{}) (bool, error) {
-	prevErrors := vm.errors
-	vm.errors = vmErrors{
-		hasErrors: false,
-		errors:    []ValidationError{},
+// execPseudo is pseudoExec without memoization.
+func (vm *vm) execPseudo(index int, instance interface{}) (bool, error) {
+	if vm.validOnly {
+		// evaluation stops at the first error, so there is no state to save
+		// other than how far the stacks have grown
+		mark := vm.mark()
+		err := vm.execSchema(index, instance)
+		if err == errInvalid {
+			vm.unwind(mark)
+			return true, nil
+		}
+
+		return false, err
 	}
 
-	if err := vm.execSchema(schema, instance); err != nil {
+	prevHasErrors := vm.errors.hasErrors
+	vm.errors.hasErrors = false
+	vm.pseudo++
+
+	err := vm.execSchema(index, instance)
+
+	pseudoHasErrors := vm.errors.hasErrors
+	vm.errors.hasErrors = prevHasErrors
+	vm.pseudo--
+
+	if err != nil {
 		return false, err
 	}
 
-	pseudoErrors := vm.errors
-	vm.errors = prevErrors
+	return pseudoHasErrors, nil
+}
 
-	return pseudoErrors.hasErrors, nil
+// stackMark records the heights of the vm's stacks.
+type stackMark struct {
+	instance, schemas, schemaTokens, keys int
+}
+
+func (vm *vm) mark() stackMark {
+	return stackMark{
+		instance:     len(vm.stack.instance),
+		schemas:      len(vm.stack.schemas),
+		schemaTokens: len(vm.stack.schemaTokens),
+		keys:         len(vm.keys),
+	}
+}
+
+// unwind returns the vm's stacks to the heights recorded in m, after
+// evaluation was stopped part way through.
+func (vm *vm) unwind(m stackMark) {
+	vm.stack.instance = vm.stack.instance[:m.instance]
+	vm.stack.schemas = vm.stack.schemas[:m.schemas]
+	vm.stack.schemaTokens = vm.stack.schemaTokens[:m.schemaTokens]
+	vm.keys = vm.keys[:m.keys]
+}
+
+// checkContext returns the error from the vm's context if that context is done.
+// To keep evaluation cheap, the context is only consulted once every
+// ctxCheckInterval calls.
+func (vm *vm) checkContext() error {
+	if vm.done == nil {
+		return nil
+	}
+
+	vm.ticks++
+	if vm.ticks%ctxCheckInterval != 0 {
+		return nil
+	}
+
+	select {
+	case <-vm.done:
+		return vm.ctx.Err()
+	default:
+		return nil
+	}
 }
 
 func (vm *vm) pushNewSchema(id url.URL, tokens []string) {
+	depth := len(vm.stack.instance)
+	hops := 1
+	if n := len(vm.stack.schemas); n > 0 && vm.stack.schemas[n-1].depth == depth {
+		hops = vm.stack.schemas[n-1].hops + 1
+	}
+
 	vm.stack.schemas = append(vm.stack.schemas, schemaStack{
-		id:     id,
-		tokens: tokens,
+		id:    id,
+		start: len(vm.stack.schemaTokens),
+		depth: depth,
+		hops:  hops,
 	})
+
+	for _, t := range tokens {
+		vm.stack.schemaTokens = append(vm.stack.schemaTokens, token{key: t})
+	}
 }
 
 func (vm *vm) popSchema() {
+	s := vm.stack.schemas[len(vm.stack.schemas)-1]
+	vm.stack.schemaTokens = vm.stack.schemaTokens[:s.start]
 	vm.stack.schemas = vm.stack.schemas[:len(vm.stack.schemas)-1]
 }
 
-func (vm *vm) pushSchemaToken(token string) {
-	s := &vm.stack.schemas[len(vm.stack.schemas)-1]
-	s.tokens = append(s.tokens, token)
+func (vm *vm) pushSchemaToken(key string) {
+	vm.stack.schemaTokens = append(vm.stack.schemaTokens, token{key: key})
+}
+
+func (vm *vm) pushSchemaIndex(index int) {
+	vm.stack.schemaTokens = append(vm.stack.schemaTokens, token{index: index, isIndex: true})
 }
 
 func (vm *vm) popSchemaToken() {
-	s := &vm.stack.schemas[len(vm.stack.schemas)-1]
-	s.tokens = s.tokens[:len(s.tokens)-1]
+	vm.stack.schemaTokens = vm.stack.schemaTokens[:len(vm.stack.schemaTokens)-1]
+}
+
+// pushInstanceToken descends into value, a member of an object within the
+// instance. It returns ErrBudgetExceeded if doing so would exceed the vm's
+// instance depth, node, string length or array length limits.
+func (vm *vm) pushInstanceToken(key string, value interface{}) error {
+	return vm.pushInstance(token{key: key}, value)
 }
 
-func (vm *vm) pushInstanceToken(token string) {
-	vm.stack.instance = append(vm.stack.instance, token)
+// pushInstanceIndex is like pushInstanceToken, but descends into an element of
+// an array.
+func (vm *vm) pushInstanceIndex(index int, value interface{}) error {
+	return vm.pushInstance(token{index: index, isIndex: true}, value)
+}
+
+func (vm *vm) pushInstance(t token, value interface{}) error {
+	if vm.maxInstanceDepth > 0 && len(vm.stack.instance) == vm.maxInstanceDepth {
+		return ErrBudgetExceeded{Budget: BudgetInstanceDepth, Limit: vm.maxInstanceDepth}
+	}
+
+	if err := vm.visitNode(); err != nil {
+		return err
+	}
+
+	if err := vm.checkLength(value); err != nil {
+		return err
+	}
+
+	vm.stack.instance = append(vm.stack.instance, t)
+	return nil
+}
+
+// checkLength checks a value within the instance against the vm's string and
+// array length limits. These apply to every value the vm visits, whatever the
+// schemas which apply to it.
+func (vm *vm) checkLength(value interface{}) error {
+	switch val := value.(type) {
+	case string:
+		return vm.checkStringLength(val)
+	case []interface{}:
+		if vm.maxArrayLength > 0 && len(val) > vm.maxArrayLength {
+			return ErrBudgetExceeded{Budget: BudgetArrayLength, Limit: vm.maxArrayLength}
+		}
+	}
+
+	return nil
+}
+
+func (vm *vm) checkStringLength(val string) error {
+	if vm.maxStringLength > 0 && len(val) > vm.maxStringLength {
+		return ErrBudgetExceeded{Budget: BudgetStringLength, Limit: vm.maxStringLength}
+	}
+
+	return nil
 }
 
 func (vm *vm) popInstanceToken() {
 	vm.stack.instance = vm.stack.instance[:len(vm.stack.instance)-1]
 }
 
-func (vm *vm) reportError() error {
-	schemaStack := vm.stack.schemas[len(vm.stack.schemas)-1]
-	instancePath := make([]string, len(vm.stack.instance))
-	schemaPath := make([]string, len(schemaStack.tokens))
+// visitNode records a visit to a value within the instance.
+func (vm *vm) visitNode() error {
+	vm.nodes++
+	if vm.maxNodes > 0 && vm.nodes > vm.maxNodes {
+		return ErrBudgetExceeded{Budget: BudgetNodes, Limit: vm.maxNodes}
+	}
+
+	return nil
+}
+
+// useFuel records the evaluation of a keyword.
+func (vm *vm) useFuel() error {
+	vm.fuel++
+	if vm.maxFuel > 0 && vm.fuel > vm.maxFuel {
+		return ErrBudgetExceeded{Budget: BudgetFuel, Limit: vm.maxFuel}
+	}
+
+	return nil
+}
 
-	copy(instancePath, vm.stack.instance)
-	copy(schemaPath, schemaStack.tokens)
+func (vm *vm) reportError() error {
+	if vm.validOnly {
+		return errInvalid
+	}
 
 	vm.errors.hasErrors = true
+	if vm.pseudo > 0 {
+		return nil
+	}
+
+	if vm.deduplicateErrors {
+		key := vm.errorKey()
+		if _, ok := vm.errors.seen[key]; ok {
+			return nil
+		}
+
+		if vm.errors.seen == nil {
+			vm.errors.seen = map[string]struct{}{}
+		}
+
+		vm.errors.seen[key] = struct{}{}
+	}
+
+	vm.errors.count++
+
+	if vm.maxErrors > 0 && len(vm.errors.errors) == vm.maxErrors {
+		// only reachable when counting all errors; the error is counted, but not
+		// kept
+		vm.errors.overflowed = true
+		return nil
+	}
+
+	schemaStack := vm.stack.schemas[len(vm.stack.schemas)-1]
+
 	vm.errors.errors = append(vm.errors.errors, ValidationError{
-		InstancePath: jsonpointer.Ptr{Tokens: instancePath},
-		SchemaPath:   jsonpointer.Ptr{Tokens: schemaPath},
+		InstancePath: jsonpointer.Ptr{Tokens: formatTokens(vm.stack.instance)},
+		SchemaPath:   jsonpointer.Ptr{Tokens: formatTokens(vm.stack.schemaTokens[schemaStack.start:])},
 		URI:          schemaStack.id,
 	})
 
-	if len(vm.errors.errors) == vm.maxErrors {
+	if len(vm.errors.errors) == vm.maxErrors && !vm.countAllErrors {
 		return errMaxErrors
 	}
 
 	return nil
 }
+
+// reportKeywordError reports an error at the given keyword of the current
+// schema.
+func (vm *vm) reportKeywordError(keyword string) error {
+	vm.pushSchemaToken(keyword)
+	if err := vm.reportError(); err != nil {
+		return err
+	}
+	vm.popSchemaToken()
+
+	return nil
+}
+
+// reportDuplicates reports an error for "uniqueItems", noting the indices of
+// the equal elements.
+func (vm *vm) reportDuplicates(i, j int) error {
+	n := len(vm.errors.errors)
+	err := vm.reportError()
+	if len(vm.errors.errors) > n {
+		vm.errors.errors[n].Duplicates = []int{i, j}
+	}
+
+	return err
+}
+
+// reportIndexError reports an error at the given index within the current
+// keyword of the current schema.
+func (vm *vm) reportIndexError(index int) error {
+	vm.pushSchemaIndex(index)
+	if err := vm.reportError(); err != nil {
+		return err
+	}
+	vm.popSchemaToken()
+
+	return nil
+}
+
+// errorKey returns a string uniquely identifying the error that would be
+// reported at the vm's current position.
+func (vm *vm) errorKey() string {
+	schemaStack := vm.stack.schemas[len(vm.stack.schemas)-1]
+	instancePtr := jsonpointer.Ptr{Tokens: formatTokens(vm.stack.instance)}
+	schemaPtr := jsonpointer.Ptr{Tokens: formatTokens(vm.stack.schemaTokens[schemaStack.start:])}
+
+	return schemaStack.id.String() + "#" + schemaPtr.String() + " " + instancePtr.String()
+}
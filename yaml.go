@@ -0,0 +1,370 @@
+package jsonschema
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// DecodeYAML converts each document in a YAML stream into the same data model
+// that encoding/json produces when decoding into an interface{}, which is the
+// data model the rest of this package expects.
+//
+// Mapping keys which are not strings, such as numbers or booleans, are
+// converted into strings. All numbers, integer or not, become float64. Aliases
+// are expanded, and merge keys ("<<") are applied.
+func DecodeYAML(data []byte) ([]interface{}, error) {
+	docs, err := ParseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		values[i] = doc.Value
+	}
+
+	return values, nil
+}
+
+// ParseYAML is like DecodeYAML, but returns each document in the stream as a
+// Document. The end positions of values in YAML documents are not recorded.
+func ParseYAML(data []byte) ([]Document, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	lines := newLineIndex(data)
+
+	docs := []Document{}
+	for {
+		var node yaml.Node
+		err := decoder.Decode(&node)
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		c := yamlConverter{
+			lines:     lines,
+			maxValues: yamlValuesPerByte * (len(data) + 1),
+			anchors:   map[*yaml.Node]*sourceNode{},
+		}
+
+		root := &sourceNode{}
+		value, err := c.Convert(&node, root)
+		if err != nil {
+			return nil, err
+		}
+
+		docs = append(docs, Document{Value: value, sourceMap: sourceMap{root: root}})
+	}
+
+	return docs, nil
+}
+
+// NewValidatorFromYAML constructs a new Validator from a YAML stream, using
+// each document in the stream as a schema.
+//
+// See NewValidator for how schemas will be used.
+func NewValidatorFromYAML(data []byte) (Validator, error) {
+	schemas, err := ParseYAML(data)
+	if err != nil {
+		return Validator{}, err
+	}
+
+	return NewValidatorFromDocuments(schemas)
+}
+
+// NewValidatorFromYAMLWithConfig is like NewValidatorFromYAML, but uses the
+// given config.
+//
+// See NewValidatorWithConfig for how config will be used.
+func NewValidatorFromYAMLWithConfig(data []byte, config ValidatorConfig) (Validator, error) {
+	schemas, err := ParseYAML(data)
+	if err != nil {
+		return Validator{}, err
+	}
+
+	return NewValidatorFromDocumentsWithConfig(schemas, config)
+}
+
+// ValidateYAML evaluates each document in a YAML stream against the default
+// schema of the Validator. One result is returned for each document.
+//
+// The InstanceStart of each error in the results refers to the position of the
+// rejected value in data.
+func (v *Validator) ValidateYAML(data []byte) ([]ValidationResult, error) {
+	docs, err := ParseYAML(data)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ValidationResult, len(docs))
+	for i, doc := range docs {
+		result, err := v.ValidateDocument(doc)
+		if err != nil {
+			return nil, err
+		}
+
+		results[i] = result
+	}
+
+	return results, nil
+}
+
+// yamlValuesPerByte bounds how many values a yamlConverter will produce, in
+// proportion to the size of the source document. Aliases let small documents
+// describe enormous values, and this guards against such "billion laughs"
+// documents.
+const yamlValuesPerByte = 64
+
+// yamlConverter converts YAML nodes into JSON values, recording the position of
+// each value it produces.
+type yamlConverter struct {
+	lines     lineIndex
+	values    int
+	maxValues int
+
+	// anchors holds where the values within each anchored node appeared, so
+	// that aliases of the node can share them
+	anchors map[*yaml.Node]*sourceNode
+}
+
+// Convert converts node into a JSON value, recording where it and the values
+// within it appeared in src. If src is nil, then positions are not recorded.
+func (c *yamlConverter) Convert(node *yaml.Node, src *sourceNode) (interface{}, error) {
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil, nil
+		}
+
+		return c.Convert(node.Content[0], src)
+	}
+
+	c.values++
+	if c.values > c.maxValues {
+		return nil, errors.New("yaml: document expands to too many values")
+	}
+
+	start := Position{}
+	if src != nil {
+		start = c.lines.LineColumn(node.Line, node.Column)
+		src.start = start
+
+		if node.Anchor != "" {
+			c.anchors[node] = src
+		}
+	}
+
+	switch node.Kind {
+	case yaml.AliasNode:
+		anchor, ok := c.anchors[node.Alias]
+		if !ok || src == nil {
+			value, err := c.Convert(node.Alias, src)
+
+			// the value is at the alias, not the anchor it refers to
+			if src != nil {
+				src.start = start
+			}
+
+			return value, err
+		}
+
+		// the values within the alias are where they are within the anchor, so
+		// their positions are shared with it rather than recorded again; the
+		// alias is expanded all the same, but its positions take no more room
+		// however often it is used
+		value, err := c.Convert(node.Alias, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		key := src.key
+		*src = *anchor
+		src.key = key
+		src.start = start
+		return value, nil
+	case yaml.ScalarNode:
+		return convertYAMLScalar(node)
+	case yaml.SequenceNode:
+		values := make([]interface{}, len(node.Content))
+		if src != nil {
+			src.elems = make([]*sourceNode, len(node.Content))
+		}
+
+		for i, elem := range node.Content {
+			var elemSrc *sourceNode
+			if src != nil {
+				elemSrc = &sourceNode{}
+				src.elems[i] = elemSrc
+			}
+
+			value, err := c.Convert(elem, elemSrc)
+			if err != nil {
+				return nil, err
+			}
+
+			values[i] = value
+		}
+
+		return values, nil
+	case yaml.MappingNode:
+		object := map[string]interface{}{}
+		if err := c.convertMapping(node, object, src, false); err != nil {
+			return nil, err
+		}
+
+		return object, nil
+	default:
+		return nil, fmt.Errorf("yaml: unsupported node kind at %d:%d", node.Line, node.Column)
+	}
+}
+
+// convertMapping adds the entries in node to object, and where they appeared to
+// src if it is not nil. If merging, then entries already in object take
+// precedence over those in node.
+func (c *yamlConverter) convertMapping(node *yaml.Node, object map[string]interface{}, src *sourceNode, merging bool) error {
+	merges := []*yaml.Node{}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode := node.Content[i]
+		valueNode := node.Content[i+1]
+
+		if keyNode.Kind == yaml.ScalarNode && keyNode.ShortTag() == "!!merge" {
+			merges = append(merges, valueNode)
+			continue
+		}
+
+		key, err := convertYAMLKey(keyNode)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := object[key]; ok && merging {
+			continue
+		}
+
+		var member *sourceNode
+		if src != nil {
+			member = &sourceNode{sourceSpan: sourceSpan{key: c.lines.LineColumn(keyNode.Line, keyNode.Column)}}
+		}
+
+		value, err := c.Convert(valueNode, member)
+		if err != nil {
+			return err
+		}
+
+		object[key] = value
+		if src != nil {
+			if src.members == nil {
+				src.members = map[string]*sourceNode{}
+			}
+
+			src.members[key] = member
+		}
+	}
+
+	// explicit entries take precedence over merged ones, so merges are
+	// performed last
+	for _, merge := range merges {
+		if err := c.merge(merge, object, src); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// merge applies the value of a merge key, which may be a mapping or a sequence
+// of mappings, to object.
+func (c *yamlConverter) merge(node *yaml.Node, object map[string]interface{}, src *sourceNode) error {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		return c.convertMapping(node, object, src, true)
+	case yaml.SequenceNode:
+		for _, elem := range node.Content {
+			if err := c.merge(elem, object, src); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("yaml: invalid merge value at %d:%d", node.Line, node.Column)
+	}
+}
+
+// convertYAMLKey converts a mapping key into a JSON object key.
+func convertYAMLKey(node *yaml.Node) (string, error) {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	if node.Kind != yaml.ScalarNode {
+		return "", fmt.Errorf("yaml: non-scalar mapping key at %d:%d", node.Line, node.Column)
+	}
+
+	if node.ShortTag() == "!!null" {
+		return "null", nil
+	}
+
+	return node.Value, nil
+}
+
+func convertYAMLScalar(node *yaml.Node) (interface{}, error) {
+	switch node.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var b bool
+		if err := node.Decode(&b); err != nil {
+			return nil, err
+		}
+
+		return b, nil
+	case "!!int":
+		var i int64
+		if err := node.Decode(&i); err == nil {
+			return float64(i), nil
+		}
+
+		var u uint64
+		if err := node.Decode(&u); err == nil {
+			return float64(u), nil
+		}
+
+		// integers too large for 64 bits are approximated, as encoding/json
+		// would do
+		f, err := strconv.ParseFloat(node.Value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("yaml: invalid integer at %d:%d", node.Line, node.Column)
+		}
+
+		return f, nil
+	case "!!float":
+		var f float64
+		if err := node.Decode(&f); err != nil {
+			return nil, err
+		}
+
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, fmt.Errorf("yaml: %s at %d:%d cannot be represented in JSON", node.Value, node.Line, node.Column)
+		}
+
+		return f, nil
+	default:
+		// strings, as well as types JSON lacks, such as timestamps and binary
+		// data, are kept as they were written
+		return node.Value, nil
+	}
+}
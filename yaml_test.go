@@ -0,0 +1,249 @@
+package jsonschema
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	jsonpointer "github.com/json-schema-spec/json-pointer-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeYAML(t *testing.T) {
+	testCases := []struct {
+		name string
+		yaml string
+		docs []interface{}
+		err  bool
+	}{
+		{
+			"scalars",
+			"[~, true, 3, 0x10, 3.5, 1e3, foo, '3', 2001-12-14]",
+			[]interface{}{
+				[]interface{}{nil, true, float64(3), float64(16), 3.5, float64(1000), "foo", "3", "2001-12-14"},
+			},
+			false,
+		},
+		{
+			"non-string keys",
+			"{1: a, true: b, ~: c, 1.5: d}",
+			[]interface{}{
+				map[string]interface{}{"1": "a", "true": "b", "null": "c", "1.5": "d"},
+			},
+			false,
+		},
+		{
+			"anchors and aliases",
+			"a: &x {b: [1, 2]}\nc: *x",
+			[]interface{}{
+				map[string]interface{}{
+					"a": map[string]interface{}{"b": []interface{}{float64(1), float64(2)}},
+					"c": map[string]interface{}{"b": []interface{}{float64(1), float64(2)}},
+				},
+			},
+			false,
+		},
+		{
+			"merge keys",
+			"base: &base {a: 1, b: 2}\nderived:\n  <<: *base\n  b: 3",
+			[]interface{}{
+				map[string]interface{}{
+					"base":    map[string]interface{}{"a": float64(1), "b": float64(2)},
+					"derived": map[string]interface{}{"a": float64(1), "b": float64(3)},
+				},
+			},
+			false,
+		},
+		{
+			"multiple documents",
+			"a: 1\n---\n- b\n---\n",
+			[]interface{}{
+				map[string]interface{}{"a": float64(1)},
+				[]interface{}{"b"},
+				nil,
+			},
+			false,
+		},
+		{
+			"empty stream",
+			"",
+			[]interface{}{},
+			false,
+		},
+		{
+			"infinity",
+			".inf",
+			nil,
+			true,
+		},
+		{
+			"non-scalar key",
+			"? [a]\n: b",
+			nil,
+			true,
+		},
+		{
+			"syntax error",
+			"a: [",
+			nil,
+			true,
+		},
+		{
+			"billion laughs",
+			strings.Join([]string{
+				"a: &a [x, x, x, x, x, x, x, x, x, x]",
+				"b: &b [*a, *a, *a, *a, *a, *a, *a, *a, *a, *a]",
+				"c: &c [*b, *b, *b, *b, *b, *b, *b, *b, *b, *b]",
+				"d: &d [*c, *c, *c, *c, *c, *c, *c, *c, *c, *c]",
+				"e: &e [*d, *d, *d, *d, *d, *d, *d, *d, *d, *d]",
+			}, "\n"),
+			nil,
+			true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			docs, err := DecodeYAML([]byte(tt.yaml))
+			if tt.err {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.docs, docs)
+			}
+		})
+	}
+}
+
+func TestParseYAMLSpans(t *testing.T) {
+	docs, err := ParseYAML([]byte("a: &x {b: [1, 2]}\nc: *x\nd: [*x]"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(docs))
+
+	testCases := []struct {
+		ptr   string
+		start Position
+		ok    bool
+	}{
+		{"/a", Position{Offset: 3, Line: 1, Column: 4}, true},
+		{"/a/b/1", Position{Offset: 14, Line: 1, Column: 15}, true},
+		// an alias is where it appears, but the values within it are where
+		// they appear within the anchor
+		{"/c", Position{Offset: 21, Line: 2, Column: 4}, true},
+		{"/c/b/1", Position{Offset: 14, Line: 1, Column: 15}, true},
+		{"/d/0", Position{Offset: 28, Line: 3, Column: 5}, true},
+		{"/d/0/b/0", Position{Offset: 11, Line: 1, Column: 12}, true},
+		{"/d/1", Position{}, false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.ptr, func(t *testing.T) {
+			ptr, err := jsonpointer.New(tt.ptr)
+			assert.NoError(t, err)
+
+			start, end, ok := docs[0].Span(ptr)
+			assert.Equal(t, tt.start, start)
+			assert.Equal(t, Position{}, end)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
+
+func TestParseYAMLAliasSpans(t *testing.T) {
+	// each alias expands to many values, but records no more positions than
+	// the alias itself
+	data := strings.Join([]string{
+		"a: &a [x, x, x, x, x, x, x, x, x, x]",
+		"b: &b [*a, *a, *a, *a, *a, *a, *a, *a, *a, *a]",
+		"c: &c [*b, *b, *b, *b, *b, *b, *b, *b, *b, *b]",
+	}, "\n")
+
+	docs, err := ParseYAML([]byte(data))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(docs))
+
+	nodes := map[*sourceNode]bool{}
+	var walk func(node *sourceNode)
+	walk = func(node *sourceNode) {
+		if nodes[node] {
+			return
+		}
+
+		nodes[node] = true
+		for _, elem := range node.elems {
+			walk(elem)
+		}
+
+		for _, member := range node.members {
+			walk(member)
+		}
+	}
+
+	walk(docs[0].sourceMap.root)
+
+	// the root, the three members, the ten scalars of a, and one node for
+	// each of the twenty aliases
+	assert.Equal(t, 34, len(nodes))
+}
+
+func TestValidatorValidateYAML(t *testing.T) {
+	schemas := `
+$id: http://example.com/address
+properties:
+  zip:
+    type: string
+---
+properties:
+  name:
+    type: string
+  addresses:
+    items:
+      $ref: http://example.com/address
+`
+
+	validator, err := NewValidatorFromYAML([]byte(schemas))
+	assert.NoError(t, err)
+
+	instances := `
+name: Alice
+addresses:
+  - zip: "12345"
+  - zip: 12345
+---
+name: 3
+`
+
+	results, err := validator.ValidateYAML([]byte(instances))
+	assert.NoError(t, err)
+	assert.Equal(t, []ValidationResult{
+		ValidationResult{
+			Errors: []ValidationError{
+				ValidationError{
+					InstancePath:  jsonpointer.Ptr{Tokens: []string{"addresses", "1", "zip"}},
+					SchemaPath:    jsonpointer.Ptr{Tokens: []string{"properties", "zip", "type"}},
+					URI:           url.URL{Scheme: "http", Host: "example.com", Path: "/address"},
+					InstanceStart: Position{Offset: 50, Line: 5, Column: 10},
+					SchemaStart:   Position{Offset: 56, Line: 5, Column: 5},
+				},
+			},
+			ErrorCount: 1,
+		},
+		ValidationResult{
+			Errors: []ValidationError{
+				ValidationError{
+					InstancePath:  jsonpointer.Ptr{Tokens: []string{"name"}},
+					SchemaPath:    jsonpointer.Ptr{Tokens: []string{"properties", "name", "type"}},
+					InstanceStart: Position{Offset: 66, Line: 7, Column: 7},
+					SchemaStart:   Position{Offset: 97, Line: 9, Column: 5},
+				},
+			},
+			ErrorCount: 1,
+		},
+	}, results)
+
+	_, err = NewValidatorFromYAMLWithConfig([]byte("type: 3"), ValidatorConfig{})
+	assert.Equal(t, ErrInvalidSchema, err)
+
+	_, err = validator.ValidateYAML([]byte("a: ["))
+	assert.Error(t, err)
+}